@@ -0,0 +1,221 @@
+// envdoc 根据配置struct的源码生成环境变量说明
+//
+//	envdoc -dir ./rediss -type config -prefix MISC -format markdown
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gqf2008/misc"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "package directory")
+	typ := flag.String("type", "", "config struct type name")
+	prefix := flag.String("prefix", "", "variable prefix passed to misc.Fill")
+	format := flag.String("format", "markdown", "output format: markdown, dotenv or configmap")
+	name := flag.String("name", "", "ConfigMap name, default is the lower case prefix")
+	flag.Parse()
+	if *typ == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	p, err := load(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	st, ok := p.structs[*typ]
+	if !ok {
+		log.Fatalf("struct type %s not found in %s", *typ, *dir)
+	}
+	var vars []misc.Var
+	p.describe(*prefix, st, &vars, map[*ast.StructType]bool{})
+
+	switch *format {
+	case "markdown", "md":
+		err = misc.WriteMarkdown(os.Stdout, vars)
+	case "dotenv", "env":
+		err = misc.WriteDotEnv(os.Stdout, vars)
+	case "configmap", "k8s":
+		n := *name
+		if n == "" {
+			n = strings.ToLower(strings.Replace(*prefix, "_", "-", -1))
+		}
+		if n == "" {
+			n = "config"
+		}
+		err = misc.WriteConfigMap(os.Stdout, n, vars)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type pkg struct {
+	name    string
+	structs map[string]*ast.StructType
+	named   map[string]bool
+	//scalars 实现了UnmarshalText或者通过RegisterParser注册过的类型，与misc.isScalar一致不展开
+	scalars map[string]bool
+}
+
+func load(dir string) (*pkg, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, ap := range pkgs {
+		p := &pkg{name: ap.Name, structs: map[string]*ast.StructType{}, named: map[string]bool{}, scalars: map[string]bool{}}
+		for _, f := range ap.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.TypeSpec:
+					p.named[n.Name.Name] = true
+					if st, ok := n.Type.(*ast.StructType); ok {
+						p.structs[n.Name.Name] = st
+					}
+					return false
+				case *ast.FuncDecl:
+					if n.Recv != nil && len(n.Recv.List) == 1 && n.Name.Name == "UnmarshalText" {
+						p.scalars[embeddedName(n.Recv.List[0].Type)] = true
+					}
+				case *ast.CallExpr:
+					if name := registeredType(n); name != "" {
+						p.scalars[name] = true
+					}
+				}
+				return true
+			})
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("no go package in %s", dir)
+}
+
+//describe 与misc.Describe的遍历规则一致，只是基于源码。seen为当前路径上已经展开的类型，自引用的字段不再展开
+func (p *pkg) describe(pf string, st *ast.StructType, vars *[]misc.Var, seen map[*ast.StructType]bool) {
+	if seen[st] {
+		return
+	}
+	seen[st] = true
+	defer delete(seen, st)
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(s)
+		}
+		names := field.Names
+		embedded := len(names) == 0
+		if embedded {
+			names = []*ast.Ident{ast.NewIdent(embeddedName(field.Type))}
+		}
+		for _, n := range names {
+			_, isPtr := field.Type.(*ast.StarExpr)
+			if !n.IsExported() && (!embedded || isPtr) {
+				continue
+			}
+			sf := reflect.StructField{Name: n.Name, Tag: tag}
+			name := misc.VarName(pf, sf)
+			if at, ok := field.Type.(*ast.ArrayType); ok && at.Len == nil {
+				if nested := p.nested(at.Elt); nested != nil {
+					p.describe(name+"_"+misc.IndexPlaceholder, nested, vars, seen)
+					continue
+				}
+			}
+			if nested := p.nested(field.Type); nested != nil {
+				p.describe(name, nested, vars, seen)
+				continue
+			}
+			*vars = append(*vars, misc.NewVar(name, p.typeString(field.Type), sf))
+		}
+	}
+}
+
+func (p *pkg) nested(e ast.Expr) *ast.StructType {
+	if se, ok := e.(*ast.StarExpr); ok {
+		e = se.X
+	}
+	switch t := e.(type) {
+	case *ast.StructType:
+		return t
+	case *ast.Ident:
+		if p.scalars[t.Name] {
+			return nil
+		}
+		return p.structs[t.Name]
+	}
+	return nil
+}
+
+//registeredType RegisterParser(T{}, ...)或RegisterParser(T(v), ...)调用注册的类型名
+func registeredType(call *ast.CallExpr) string {
+	var fn string
+	switch f := call.Fun.(type) {
+	case *ast.Ident:
+		fn = f.Name
+	case *ast.SelectorExpr:
+		fn = f.Sel.Name
+	}
+	if fn != "RegisterParser" || len(call.Args) == 0 {
+		return ""
+	}
+	switch a := call.Args[0].(type) {
+	case *ast.CompositeLit:
+		if id, ok := a.Type.(*ast.Ident); ok {
+			return id.Name
+		}
+	case *ast.CallExpr:
+		if id, ok := a.Fun.(*ast.Ident); ok && len(a.Args) == 1 {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+func (p *pkg) typeString(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if p.named[t.Name] {
+			return p.name + "." + t.Name
+		}
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + p.typeString(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + p.typeString(t.Elt)
+		}
+	case *ast.MapType:
+		return "map[" + p.typeString(t.Key) + "]" + p.typeString(t.Value)
+	}
+	return types.ExprString(e)
+}
+
+func embeddedName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+// idserver 以HTTP提供ID发号服务，配置从IDSERVER_开头的环境变量读取
+//
+//	IDSERVER_NODE=3 IDSERVER_LAYOUT=snowflake idserver
+//	curl localhost:8080/ids?n=10
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gqf2008/misc/idserver"
+)
+
+func main() {
+	conf, err := idserver.LoadConfig("IDSERVER")
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv, err := idserver.NewFromConfig(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hs := &http.Server{Addr: conf.Addr, Handler: srv}
+	//Shutdown返回前ListenAndServe就已经返回，等处理中的请求结束后再关闭发号器
+	idle := make(chan struct{})
+	go func() {
+		defer close(idle)
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		<-c
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := hs.Shutdown(ctx); err != nil {
+			log.Println("shutdown", err)
+		}
+	}()
+	log.Println("idserver listening on", conf.Addr, "node", conf.Node)
+	if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-idle
+	if err := srv.Close(); err != nil {
+		log.Println(err)
+	}
+}
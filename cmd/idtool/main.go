@@ -0,0 +1,97 @@
+// idtool 解析ID并以JSON输出各个组成部分
+//
+//	idtool 3517066276804493313
+//	idtool -layout snowflake 26101810495205310230001
+//	idtool -enc base32 31KS14M070001Y
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gqf2008/misc"
+)
+
+type output struct {
+	misc.IDInfo
+	Base62   string `json:"base62"`
+	Base32   string `json:"base32"`
+	Sortable string `json:"sortable"`
+}
+
+func main() {
+	layoutName := flag.String("layout", "default", "id layout: default or snowflake")
+	enc := flag.String("enc", "auto", "input encoding: auto, number, formatted, base62, base32 or sortable")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var layout misc.Layout
+	switch *layoutName {
+	case "default":
+		layout = misc.DefaultLayout
+	case "snowflake":
+		layout = misc.SnowflakeLayout
+	default:
+		log.Fatalf("unknown layout %s", *layoutName)
+	}
+
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	code := 0
+	for _, arg := range flag.Args() {
+		id, err := parse(layout, *enc, arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, arg, err)
+			code = 1
+			continue
+		}
+		_ = e.Encode(output{
+			IDInfo:   layout.Inspect(id),
+			Base62:   misc.FormatIDBase62(id),
+			Base32:   misc.FormatIDBase32(id),
+			Sortable: misc.FormatIDSortable(id),
+		})
+	}
+	os.Exit(code)
+}
+
+func parse(layout misc.Layout, enc, s string) (uint64, error) {
+	if enc == "auto" {
+		enc = detect(s)
+	}
+	switch enc {
+	case "number":
+		return strconv.ParseUint(s, 10, 64)
+	case "formatted":
+		return layout.Parse(s)
+	case "base62":
+		return misc.ParseIDBase62(s)
+	case "base32":
+		return misc.ParseIDBase32(s)
+	case "sortable":
+		return misc.ParseIDSortable(s)
+	}
+	return 0, fmt.Errorf("unknown encoding %s", enc)
+}
+
+//detect 纯数字且不超过20位为数值，超过20位为FormatID的格式，
+//否则校验符正确时按base32，其余按base62
+func detect(s string) string {
+	if strings.Trim(s, "0123456789") == "" {
+		if len(s) <= 20 {
+			return "number"
+		}
+		return "formatted"
+	}
+	if _, err := misc.ParseIDBase32(s); err == nil {
+		return "base32"
+	}
+	return "base62"
+}
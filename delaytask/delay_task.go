@@ -1,6 +1,7 @@
 package delaytask
 
 import (
+	"context"
 	"log"
 	"net/url"
 	"strconv"
@@ -111,6 +112,15 @@ func (t *DelayTask) Stop() {
 	}
 }
 
+//StopAndWait 停止取任务，等待已经取出的任务执行完，返回ctx结束时还没有执行完的任务数
+func (t *DelayTask) StopAndWait(ctx context.Context) (int, error) {
+	t.stop <- struct{}{}
+	if t.pool == nil {
+		return 0, nil
+	}
+	return t.pool.StopAndWait(ctx)
+}
+
 //Add ....
 func (t *DelayTask) Add(task interface{}, deadline time.Duration) error {
 	return t.cli.ZAdd(t.name, redis.Z{Member: task, Score: float64(deadline)}).Err()
@@ -155,13 +165,16 @@ func (t *DelayTask) loop() {
 			if res.Val() == 0 {
 				continue
 			}
-			for {
-				if t.pool.Serve(func() {
-					t.afterFunc(val.Member, time.Duration(val.Score))
-				}) {
-					break
+			member, score := val.Member, val.Score
+			if err := t.pool.Submit(context.Background(), func() {
+				t.afterFunc(member, time.Duration(score))
+			}); err != nil {
+				//WorkerPool已经停止，放回去等下次执行
+				log.Println("delay_task", t.name, err)
+				if err := t.Add(member, time.Duration(score)); err != nil {
+					log.Println("zadd delay_task", t.name, member, err)
 				}
-				time.Sleep(time.Millisecond * 500)
+				return
 			}
 		}
 		time.Sleep(time.Second)
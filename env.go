@@ -1,11 +1,12 @@
 package misc
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,12 +15,84 @@ var (
 )
 var env *Env
 
+//Env 从一组有序的配置来源读取配置，同一个变量出现在多个来源时，后面的来源覆盖前面的来源，
+//所有来源都没有时使用default标签
 type Env struct {
-	prefix string
+	prefix   string
+	sources  []Source
+	interval time.Duration
+	profile  string
+
+	lock    sync.RWMutex
+	origins map[string]string
 }
 
 func init() {
-	env = &Env{""}
+	env = NewEnv(OSEnv())
+}
+
+//NewEnv 创建Env，sources按优先级从低到高排列，例如
+//NewEnv(dotenv, file, OSEnv(), Overrides(m))
+func NewEnv(sources ...Source) *Env {
+	return &Env{sources: sources}
+}
+
+//Default 返回包级别Fill使用的Env
+func Default() *Env {
+	return env
+}
+
+//SetDefault 替换包级别Fill使用的Env
+func SetDefault(e *Env) {
+	if e == nil {
+		panic("misc: nil Env")
+	}
+	env = e
+}
+
+//WithWatchInterval 设置Watch检查配置文件变化的间隔，默认2秒
+func (e *Env) WithWatchInterval(d time.Duration) *Env {
+	e.interval = d
+	return e
+}
+
+//Sources 返回配置来源，按优先级从低到高排列
+func (e *Env) Sources() []Source {
+	return append([]Source(nil), e.sources...)
+}
+
+//Origin 返回最近一次填充变量name的Fill中该变量的来源名称，default标签提供的值返回"default"，未设置返回""
+func (e *Env) Origin(name string) string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.origins[name]
+}
+
+//Origins 返回每个变量最近一次被Fill时的来源，不同prefix的Fill互不覆盖
+func (e *Env) Origins() map[string]string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	m := make(map[string]string, len(e.origins))
+	for k, v := range e.origins {
+		m[k] = v
+	}
+	return m
+}
+
+//lookup 从优先级最高的来源开始查找，profile不为空时，同一个来源中KEY__PROFILE优先于KEY
+func (e *Env) lookup(key, profile string) (string, string, bool) {
+	pk := profileKey(key, profile)
+	for i := len(e.sources) - 1; i >= 0; i-- {
+		if profile != "" {
+			if v, ok := e.sources[i].Lookup(pk); ok {
+				return v, e.sources[i].Name() + "(" + profile + ")", true
+			}
+		}
+		if v, ok := e.sources[i].Lookup(key); ok {
+			return v, e.sources[i].Name(), true
+		}
+	}
+	return "", "", false
 }
 
 func upper(v string) string {
@@ -36,11 +109,49 @@ func (e *Env) Fill(prefix string, v interface{}) error {
 		return fmt.Errorf("only the pointer to a struct is supported")
 	}
 
-	err := fill(prefix, ind)
-	if err != nil {
-		return err
+	return e.run(prefix, ind).err()
+}
+
+func (e *Env) run(prefix string, ind reflect.Value) *fillState {
+	st := newFillState(e.Profile())
+	e.fill(prefix, ind, st)
+	e.lock.Lock()
+	//按变量合并，多个包共用同一个Env时互不覆盖
+	if e.origins == nil {
+		e.origins = map[string]string{}
+	}
+	for _, name := range st.visited {
+		delete(e.origins, name)
 	}
-	return nil
+	for name, src := range st.origins {
+		e.origins[name] = src
+	}
+	e.lock.Unlock()
+	return st
+}
+
+//fillState 一次Fill过程中收集的来源和错误
+type fillState struct {
+	profile string
+	visited []string
+	origins map[string]string
+	values  map[string]string
+	errs    FieldErrors
+}
+
+func newFillState(profile string) *fillState {
+	return &fillState{profile: profile, origins: map[string]string{}, values: map[string]string{}}
+}
+
+func (st *fillState) fail(name, rule string, err error) {
+	st.errs = append(st.errs, &FieldError{Name: name, Rule: rule, Err: err})
+}
+
+func (st *fillState) err() error {
+	if len(st.errs) == 0 {
+		return nil
+	}
+	return st.errs
 }
 
 func combine(p, n string, sep string, ok bool) string {
@@ -60,175 +171,191 @@ func parseBool(v string) (bool, error) {
 	return strconv.ParseBool(v)
 }
 
-func fill(pf string, ind reflect.Value) error {
+//fieldName 字段对应的变量名，env:",inline"的字段不增加前缀
+func fieldName(pf string, f reflect.StructField) string {
+	name, inline := parseEnvTag(f)
+	if inline {
+		return pf
+	}
+	s, exist := f.Tag.Lookup("sep")
+	return combine(pf, upper(name), s, exist)
+}
+
+//parseEnvTag 解析env标签，格式为"名称[,inline]"，名称为空时使用字段名
+func parseEnvTag(f reflect.StructField) (string, bool) {
+	name := f.Name
+	tag, exist := f.Tag.Lookup("env")
+	if !exist {
+		return name, false
+	}
+	opts := strings.Split(tag, ",")
+	if opts[0] != "" {
+		name = opts[0]
+	}
+	for _, o := range opts[1:] {
+		if strings.TrimSpace(o) == "inline" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+//skipField 不可设置的字段跳过，嵌入的非导出struct仍然可以设置其导出字段
+func skipField(f reflect.StructField) bool {
+	if f.PkgPath == "" {
+		return false
+	}
+	return !f.Anonymous || f.Type.Kind() != reflect.Struct
+}
+
+//indexElem 判断字段是否为按下标展开的struct slice，返回元素的struct类型
+func indexElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice {
+		return nil, false
+	}
+	et := t.Elem()
+	if !isNested(et) {
+		return nil, false
+	}
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	return et, true
+}
+
+func indexName(pf string, i int) string {
+	return combine(pf, strconv.Itoa(i), defaultSep, false)
+}
+
+func (e *Env) fill(pf string, ind reflect.Value, st *fillState) {
 	for i := 0; i < ind.NumField(); i++ {
 		f := ind.Type().Field(i)
-		name := f.Name
-		envName, exist := f.Tag.Lookup("env")
-		if exist {
-			name = envName
+		if skipField(f) {
+			continue
+		}
+		p := fieldName(pf, f)
+		fv := ind.Field(i)
+		if _, ok := indexElem(f.Type); ok {
+			e.fillIndexed(p, fv, f, st)
+			continue
 		}
-		s, exist := f.Tag.Lookup("sep")
-		p := combine(pf, upper(name), s, exist)
-		switch ind.Field(i).Kind() {
-		case reflect.Struct:
-			err := fill(p, ind.Field(i))
-			if err != nil {
-				return err
+		switch {
+		case isNested(f.Type):
+			if fv.Kind() == reflect.Ptr {
+				//nil的struct指针只在有变量被设置时才分配，nil表示这一段没有配置，
+				//也避免自引用的类型无限递归
+				if fv.IsNil() {
+					if !e.present(p, f.Type.Elem(), st.profile) {
+						continue
+					}
+					fv.Set(reflect.New(f.Type.Elem()))
+				}
+				fv = fv.Elem()
 			}
+			e.fill(p, fv, st)
 		default:
-			err := parse(p, ind.Field(i), f)
-			if err != nil {
-				return err
+			e.parse(p, fv, f, st)
+		}
+	}
+}
+
+//fillIndexed 按PREFIX_0_NAME、PREFIX_1_NAME...填充struct slice，
+//下标i的元素没有任何变量时结束，没有任何元素时保留字段原值
+func (e *Env) fillIndexed(pf string, fv reflect.Value, sf reflect.StructField, st *fillState) {
+	et, _ := indexElem(sf.Type)
+	sv := reflect.MakeSlice(sf.Type, 0, 0)
+	for i := 0; e.present(indexName(pf, i), et, st.profile); i++ {
+		item := reflect.New(et)
+		e.fill(indexName(pf, i), item.Elem(), st)
+		if sf.Type.Elem().Kind() == reflect.Ptr {
+			sv = reflect.Append(sv, item)
+		} else {
+			sv = reflect.Append(sv, item.Elem())
+		}
+	}
+	if sv.Len() == 0 {
+		if required, _ := isRequired(sf, st.profile); required {
+			st.fail(pf, "require", errors.New("at least one item is required, but none has been set"))
+		}
+		return
+	}
+	fv.Set(sv)
+	validate(pf, fv, sf, st)
+}
+
+//present 判断t在前缀pf下是否有任何变量被设置，default标签不算
+func (e *Env) present(pf string, t reflect.Type, profile string) bool {
+	return e.presentIn(pf, t, profile, map[reflect.Type]bool{})
+}
+
+//presentIn seen为当前路径上已经展开的类型，自引用的字段不再展开
+func (e *Env) presentIn(pf string, t reflect.Type, profile string, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if skipField(f) {
+			continue
+		}
+		p := fieldName(pf, f)
+		if et, ok := indexElem(f.Type); ok {
+			if e.presentIn(indexName(p, 0), et, profile, seen) {
+				return true
 			}
+			continue
+		}
+		if isNested(f.Type) {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if e.presentIn(p, ft, profile, seen) {
+				return true
+			}
+			continue
+		}
+		if _, _, ok := e.lookup(p, profile); ok {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func parse(prefix string, f reflect.Value, sf reflect.StructField) error {
+func (e *Env) parse(prefix string, f reflect.Value, sf reflect.StructField, st *fillState) {
+	st.visited = append(st.visited, prefix)
 	df := sf.Tag.Get("default")
-	isRequire, err := parseBool(sf.Tag.Get("require"))
+	isRequire, err := isRequired(sf, st.profile)
 	if err != nil {
-		return fmt.Errorf("the value of %s is not a valid `member` of bool ，only "+
-			"[1 0 t f T F true false TRUE FALSE True False] are supported", prefix)
+		st.fail(prefix, "require", fmt.Errorf("the value of tag is not a valid `member` of bool ，only "+
+			"[1 0 t f T F true false TRUE FALSE True False] are supported"))
+		return
 	}
-	ev, exist := os.LookupEnv(prefix)
+	ev, src, exist := e.lookup(prefix, st.profile)
 
 	if !exist && isRequire {
-		return fmt.Errorf("%s is required, but has not been set", prefix)
+		st.fail(prefix, "require", errors.New("is required, but has not been set"))
+		return
 	}
 	if !exist && df != "" {
 		ev = df
+		src = "default"
 	}
-	//log.Print("ev:", ev)
-	switch f.Kind() {
-	case reflect.String:
-		f.SetString(ev)
-	case reflect.Int:
-		iv, err := strconv.ParseInt(ev, 10, 32)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetInt(iv)
-	case reflect.Int64:
-		if f.Type().String() == "time.Duration" {
-			t, err := time.ParseDuration(ev)
-			if err != nil {
-				return fmt.Errorf("%s:%s", prefix, err)
-			}
-			f.Set(reflect.ValueOf(t))
-		} else {
-			iv, err := strconv.ParseInt(ev, 10, 64)
-			if err != nil {
-				return fmt.Errorf("%s:%s", prefix, err)
-			}
-			f.SetInt(iv)
-		}
-	case reflect.Uint:
-		uiv, err := strconv.ParseUint(ev, 10, 32)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetUint(uiv)
-	case reflect.Uint64:
-		uiv, err := strconv.ParseUint(ev, 10, 64)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetUint(uiv)
-	case reflect.Float32:
-		f32, err := strconv.ParseFloat(ev, 32)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetFloat(f32)
-	case reflect.Float64:
-		f64, err := strconv.ParseFloat(ev, 64)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetFloat(f64)
-	case reflect.Bool:
-		b, err := parseBool(ev)
-		if err != nil {
-			return fmt.Errorf("%s:%s", prefix, err)
-		}
-		f.SetBool(b)
-	case reflect.Slice:
-		sep := ";"
-		s, exist := sf.Tag.Lookup("slice_sep")
-		if exist && s != "" {
-			sep = s
-		}
-		vals := strings.Split(ev, sep)
-		switch f.Type() {
-		case reflect.TypeOf([]string{}):
-			f.Set(reflect.ValueOf(vals))
-		case reflect.TypeOf([]int{}):
-			t := make([]int, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseInt(v, 10, 32)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = int(val)
-			}
-		case reflect.TypeOf([]int64{}):
-			t := make([]int64, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseInt(v, 10, 64)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = val
-			}
-		case reflect.TypeOf([]uint{}):
-			t := make([]uint, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseUint(v, 10, 32)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = uint(val)
-			}
-		case reflect.TypeOf([]uint64{}):
-			t := make([]uint64, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseUint(v, 10, 64)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = val
-			}
-		case reflect.TypeOf([]float32{}):
-			t := make([]float32, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseFloat(v, 32)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = float32(val)
-			}
-		case reflect.TypeOf([]float64{}):
-			t := make([]float64, len(vals))
-			for i, v := range vals {
-				val, err := strconv.ParseFloat(v, 64)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = val
-			}
-		case reflect.TypeOf([]bool{}):
-			t := make([]bool, len(vals))
-			for i, v := range vals {
-				val, err := parseBool(v)
-				if err != nil {
-					return fmt.Errorf("%s:%s", prefix, err)
-				}
-				t[i] = val
-			}
-		}
+	if !exist && df == "" {
+		return
+	}
+	ev, err = resolveRef(ev, sf)
+	if err != nil {
+		st.fail(prefix, "resolve", err)
+		return
+	}
+	st.origins[prefix] = src
+	st.values[prefix] = ev
+	if err := setValue(f, ev, sf); err != nil {
+		st.fail(prefix, "parse", err)
+		return
 	}
-	return nil
+	validate(prefix, f, sf, st)
 }
@@ -0,0 +1,190 @@
+package misc
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//Var 一个配置变量的说明
+type Var struct {
+	Name     string
+	Type     string
+	Default  string
+	Required bool
+	Profile  string
+	Secret   bool
+	Desc     string
+	SliceSep string
+}
+
+//IndexPlaceholder Describe中struct slice下标的占位符，例如APP_BROKERS_<N>_URL，
+//WriteDotEnv和WriteConfigMap输出时替换为0
+const IndexPlaceholder = "<N>"
+
+//VarName 返回字段对应的变量名，命名规则与Fill一致
+func VarName(prefix string, sf reflect.StructField) string {
+	return fieldName(prefix, sf)
+}
+
+//NewVar 根据字段标签生成变量说明，typ为字段类型的名称
+func NewVar(name, typ string, sf reflect.StructField) Var {
+	required, _ := isRequired(sf, "")
+	v := Var{
+		Name:     name,
+		Type:     typ,
+		Default:  sf.Tag.Get("default"),
+		Required: required,
+		Profile:  sf.Tag.Get("profile"),
+		Secret:   isSecret(sf),
+		Desc:     sf.Tag.Get("desc"),
+	}
+	if strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[") {
+		def := ";"
+		if strings.HasPrefix(typ, "map[") {
+			def = ","
+		}
+		v.SliceSep = sliceSep(sf, def)
+	}
+	return v
+}
+
+//Describe 按Fill的规则列出v会读取的全部变量，v为struct或者指向struct的指针
+func Describe(prefix string, v interface{}) ([]Var, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("only the struct or pointer to a struct is supported")
+	}
+	var vars []Var
+	describe(prefix, t, &vars)
+	return vars, nil
+}
+
+func describe(pf string, t reflect.Type, vars *[]Var) {
+	describeIn(pf, t, vars, map[reflect.Type]bool{})
+}
+
+//describeIn seen为当前路径上已经展开的类型，自引用的字段不再展开
+func describeIn(pf string, t reflect.Type, vars *[]Var, seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if skipField(sf) {
+			continue
+		}
+		p := fieldName(pf, sf)
+		if et, ok := indexElem(sf.Type); ok {
+			describeIn(combine(p, IndexPlaceholder, defaultSep, false), et, vars, seen)
+			continue
+		}
+		if isNested(sf.Type) {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			describeIn(p, ft, vars, seen)
+			continue
+		}
+		*vars = append(*vars, NewVar(p, sf.Type.String(), sf))
+	}
+}
+
+//WriteMarkdown 以Markdown表格输出变量说明
+func WriteMarkdown(w io.Writer, vars []Var) error {
+	var sb strings.Builder
+	sb.WriteString("| Name | Type | Default | Required | Description |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, v := range vars {
+		typ := v.Type
+		if v.SliceSep != "" {
+			typ += fmt.Sprintf(" (sep `%s`)", v.SliceSep)
+		}
+		def := v.Default
+		if def != "" {
+			def = "`" + redactURL(def) + "`"
+		}
+		desc := v.Desc
+		if v.Secret {
+			desc = strings.TrimSuffix("(secret) "+desc, " ")
+		}
+		required := strconv.FormatBool(v.Required)
+		if v.Profile != "" {
+			required = "in " + v.Profile
+		}
+		fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s |\n", v.Name, mdEscape(typ), mdEscape(def), required, mdEscape(desc))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func mdEscape(s string) string {
+	return strings.Replace(s, "|", "\\|", -1)
+}
+
+//WriteDotEnv 输出.env模板，默认值作为变量值，说明、类型写在注释中
+func WriteDotEnv(w io.Writer, vars []Var) error {
+	var sb strings.Builder
+	for _, v := range vars {
+		comment := v.Type
+		if v.Required {
+			comment += ", required"
+		}
+		if v.Profile != "" {
+			comment += ", required in " + v.Profile
+		}
+		if v.Secret {
+			comment += ", secret"
+		}
+		if v.Desc != "" {
+			fmt.Fprintf(&sb, "# %s (%s)\n", v.Desc, comment)
+		} else {
+			fmt.Fprintf(&sb, "# %s\n", comment)
+		}
+		def := v.Default
+		if v.Secret {
+			def = ""
+		}
+		fmt.Fprintf(&sb, "%s=%s\n", firstIndex(v.Name), dotEnvQuote(def))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func firstIndex(name string) string {
+	return strings.Replace(name, IndexPlaceholder, "0", -1)
+}
+
+func dotEnvQuote(s string) string {
+	if strings.ContainsAny(s, " #\"'\\\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+//WriteConfigMap 输出Kubernetes ConfigMap，secret变量不写入ConfigMap，只留下注释
+func WriteConfigMap(w io.Writer, name string, vars []Var) error {
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n")
+	fmt.Fprintf(&sb, "  name: %s\ndata:\n", name)
+	for _, v := range vars {
+		if v.Desc != "" {
+			fmt.Fprintf(&sb, "  # %s\n", v.Desc)
+		}
+		if v.Secret {
+			fmt.Fprintf(&sb, "  # %s is secret, set it from a Secret\n", firstIndex(v.Name))
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", firstIndex(v.Name), strconv.Quote(v.Default))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,107 @@
+package misc
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//BindFlags 基于默认Env绑定命令行参数，默认Env本身不受影响
+func BindFlags(fs *flag.FlagSet, prefix string, v interface{}) (*Env, error) {
+	return env.BindFlags(fs, prefix, v)
+}
+
+//BindFlags 为v的每个字段注册一个命令行参数，参数名为去掉prefix后的变量名转小写并把_换成-，
+//例如MISC_REDIS_ADDR对应--redis-addr。返回在e的来源之后加上命令行参数的新Env，e本身不变，
+//fs.Parse之后在返回的Env上调用Fill，优先级为参数 > 配置来源 > default标签。
+//struct slice字段不注册参数
+func (e *Env) BindFlags(fs *flag.FlagSet, prefix string, v interface{}) (*Env, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("only the pointer to a struct is supported")
+	}
+	src := &flagSource{fs: fs, flags: map[string]*flagValue{}}
+	var vars []Var
+	describe(prefix, t.Elem(), &vars)
+	for _, vr := range vars {
+		if strings.Contains(vr.Name, IndexPlaceholder) {
+			continue
+		}
+		name := flagName(prefix, vr.Name)
+		if fs.Lookup(name) != nil {
+			return nil, fmt.Errorf("flag %s for %s already defined", name, vr.Name)
+		}
+		//def只用于帮助信息，与WriteMarkdown一样隐藏secret和URL中的密码
+		def := redactURL(vr.Default)
+		if vr.Secret {
+			def = ""
+		}
+		fv := &flagValue{def: def, isBool: vr.Type == "bool" || vr.Type == "*bool"}
+		usage := vr.Desc
+		if usage != "" {
+			usage += " "
+		}
+		usage += "(env " + vr.Name + ")"
+		fs.Var(fv, name, usage)
+		src.flags[vr.Name] = fv
+	}
+	return &Env{
+		prefix:   e.prefix,
+		sources:  append(e.Sources(), src),
+		interval: e.interval,
+		profile:  e.profile,
+	}, nil
+}
+
+func flagName(prefix, name string) string {
+	if prefix != "" && strings.HasPrefix(name, prefix) {
+		name = strings.TrimLeft(strings.TrimPrefix(name, prefix), "_")
+	}
+	return strings.Replace(strings.ToLower(name), "_", "-", -1)
+}
+
+//flagSource 只返回命令行中显式设置过的参数
+type flagSource struct {
+	fs    *flag.FlagSet
+	flags map[string]*flagValue
+}
+
+func (s *flagSource) Name() string {
+	return "flag"
+}
+
+func (s *flagSource) Lookup(key string) (string, bool) {
+	fv, ok := s.flags[key]
+	if !ok || !fv.set {
+		return "", false
+	}
+	return fv.val, true
+}
+
+type flagValue struct {
+	val    string
+	def    string
+	set    bool
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	if v.set {
+		return v.val
+	}
+	return v.def
+}
+
+func (v *flagValue) Set(s string) error {
+	v.val = s
+	v.set = true
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
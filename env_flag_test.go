@@ -0,0 +1,43 @@
+package misc
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestBindFlagsHidesSecretDefaults(t *testing.T) {
+	var c struct {
+		Addr    string `default:"redis://u:pass@h:1/0"`
+		Token   string `default:"tok123" secret:"true"`
+		Timeout int    `default:"5"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+	e, err := BindFlags(fs, "APP", &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.PrintDefaults()
+	usage := out.String()
+	for _, leak := range []string{"pass", "tok123"} {
+		if strings.Contains(usage, leak) {
+			t.Errorf("usage leaks %q:\n%s", leak, usage)
+		}
+	}
+	if !strings.Contains(usage, "(default 5)") {
+		t.Errorf("usage should keep plain defaults:\n%s", usage)
+	}
+
+	if err := fs.Parse([]string{"-timeout", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Fill("APP", &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != "redis://u:pass@h:1/0" || c.Token != "tok123" || c.Timeout != 7 {
+		t.Fatalf("Fill = %+v", c)
+	}
+}
@@ -0,0 +1,75 @@
+package misc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//Marshal Fill的逆操作，按相同的命名和分隔符规则把v输出为"KEY=value"，
+//nil指针字段不输出，struct slice中的nil元素被跳过，后面的元素依次使用下一个下标，
+//结果可以直接作为exec.Cmd的Env
+func Marshal(prefix string, v interface{}) ([]string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("only the struct or pointer to a struct is supported")
+	}
+	var kvs []string
+	marshal(prefix, rv, &kvs)
+	return kvs, nil
+}
+
+//MarshalDotEnv 与Marshal相同，但输出为.env文件内容，必要时给值加上引号
+func MarshalDotEnv(prefix string, v interface{}) ([]byte, error) {
+	kvs, err := Marshal(prefix, v)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, kv := range kvs {
+		i := strings.Index(kv, "=")
+		sb.WriteString(kv[:i+1])
+		sb.WriteString(dotEnvQuote(kv[i+1:]))
+		sb.WriteString("\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+func marshal(pf string, ind reflect.Value, kvs *[]string) {
+	for i := 0; i < ind.NumField(); i++ {
+		sf := ind.Type().Field(i)
+		if skipField(sf) {
+			continue
+		}
+		p := fieldName(pf, sf)
+		fv := ind.Field(i)
+		if _, ok := indexElem(sf.Type); ok {
+			//Fill遇到第一个没有变量的下标就结束，nil或者没有输出任何变量的元素不占用下标
+			n := 0
+			for j := 0; j < fv.Len(); j++ {
+				item := reflect.Indirect(fv.Index(j))
+				if !item.IsValid() {
+					continue
+				}
+				var sub []string
+				marshal(indexName(p, n), item, &sub)
+				if len(sub) > 0 {
+					*kvs = append(*kvs, sub...)
+					n++
+				}
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			continue
+		}
+		if isNested(sf.Type) {
+			marshal(p, reflect.Indirect(fv), kvs)
+			continue
+		}
+		*kvs = append(*kvs, p+"="+formatValue(fv, sf))
+	}
+}
@@ -0,0 +1,44 @@
+package misc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type marshalBroker struct {
+	URL string
+}
+
+type marshalConfig struct {
+	Name    string
+	Brokers []*marshalBroker
+}
+
+func TestMarshalSkipsNilElements(t *testing.T) {
+	in := marshalConfig{
+		Name:    "a",
+		Brokers: []*marshalBroker{nil, {URL: "amqp://b"}, nil, {URL: "amqp://c"}},
+	}
+	kvs, err := Marshal("APP", &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"APP_NAME=a", "APP_BROKERS_0_URL=amqp://b", "APP_BROKERS_1_URL=amqp://c"}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Marshal = %v, want %v", kvs, want)
+	}
+
+	vals := map[string]string{}
+	for _, kv := range kvs {
+		p := strings.SplitN(kv, "=", 2)
+		vals[p[0]] = p[1]
+	}
+	var out marshalConfig
+	if err := NewEnv(Overrides(vals)).Fill("APP", &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Brokers) != 2 || out.Brokers[0].URL != "amqp://b" || out.Brokers[1].URL != "amqp://c" {
+		t.Fatalf("round trip Brokers = %+v", out.Brokers)
+	}
+}
@@ -0,0 +1,178 @@
+package misc
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ParseFunc 把字符串解析为指定类型的值，返回值的类型必须与注册的类型一致
+type ParseFunc func(v string) (interface{}, error)
+
+var (
+	parsersLock sync.RWMutex
+	parsers     = map[reflect.Type]ParseFunc{}
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func init() {
+	RegisterParser(time.Duration(0), func(v string) (interface{}, error) {
+		return time.ParseDuration(v)
+	})
+	RegisterParser(url.URL{}, func(v string) (interface{}, error) {
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	})
+	RegisterParser(net.IPNet{}, func(v string) (interface{}, error) {
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		return *n, nil
+	})
+}
+
+//RegisterParser 为sample的类型注册解析函数，Fill遇到该类型（或指向该类型的指针）时使用fn解析，
+//已注册的struct类型不再按嵌套结构展开
+func RegisterParser(sample interface{}, fn ParseFunc) {
+	t := reflect.TypeOf(sample)
+	if t == nil || fn == nil {
+		panic("misc: RegisterParser with nil type or func")
+	}
+	parsersLock.Lock()
+	parsers[t] = fn
+	parsersLock.Unlock()
+}
+
+func lookupParser(t reflect.Type) (ParseFunc, bool) {
+	parsersLock.RLock()
+	fn, ok := parsers[t]
+	parsersLock.RUnlock()
+	return fn, ok
+}
+
+//isScalar 判断类型是否作为单个变量解析
+func isScalar(t reflect.Type) bool {
+	if _, ok := lookupParser(t); ok {
+		return true
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+//isNested 判断字段是否按嵌套结构展开
+func isNested(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+func setValue(f reflect.Value, ev string, sf reflect.StructField) error {
+	t := f.Type()
+	if fn, ok := lookupParser(t); ok {
+		v, err := fn(ev)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(v).Convert(t))
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		nv := reflect.New(t.Elem())
+		if err := setValue(nv.Elem(), ev, sf); err != nil {
+			return err
+		}
+		f.Set(nv)
+		return nil
+	}
+	if f.CanAddr() && f.Addr().Type().Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(ev))
+	}
+	switch t.Kind() {
+	case reflect.String:
+		f.SetString(ev)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		iv, err := strconv.ParseInt(ev, 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(iv)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uiv, err := strconv.ParseUint(ev, 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(uiv)
+	case reflect.Float32, reflect.Float64:
+		fv, err := strconv.ParseFloat(ev, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(fv)
+	case reflect.Bool:
+		b, err := parseBool(ev)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		vals := splitValues(ev, sliceSep(sf, ";"))
+		sv := reflect.MakeSlice(t, len(vals), len(vals))
+		for i, v := range vals {
+			if err := setValue(sv.Index(i), v, sf); err != nil {
+				return fmt.Errorf("[%d]:%s", i, err)
+			}
+		}
+		f.Set(sv)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		kvSep := "="
+		if s, ok := sf.Tag.Lookup("kv_sep"); ok && s != "" {
+			kvSep = s
+		}
+		mv := reflect.MakeMap(t)
+		for _, pair := range splitValues(ev, sliceSep(sf, ",")) {
+			kv := strings.SplitN(pair, kvSep, 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid map entry %q", pair)
+			}
+			k := reflect.New(t.Key()).Elem()
+			k.SetString(strings.TrimSpace(kv[0]))
+			v := reflect.New(t.Elem()).Elem()
+			if err := setValue(v, strings.TrimSpace(kv[1]), sf); err != nil {
+				return fmt.Errorf("[%s]:%s", kv[0], err)
+			}
+			mv.SetMapIndex(k, v)
+		}
+		f.Set(mv)
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+func sliceSep(sf reflect.StructField, def string) string {
+	if s, ok := sf.Tag.Lookup("slice_sep"); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+func splitValues(ev, sep string) []string {
+	if ev == "" {
+		return nil
+	}
+	return strings.Split(ev, sep)
+}
@@ -0,0 +1,91 @@
+package misc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+//ProfileVar 选择profile的变量名
+const ProfileVar = "MISC_PROFILE"
+
+//ActiveProfile 返回进程环境变量MISC_PROFILE的值
+func ActiveProfile() string {
+	return os.Getenv(ProfileVar)
+}
+
+//WithProfile 指定profile，不指定时每次Fill从配置来源中读取MISC_PROFILE
+func (e *Env) WithProfile(profile string) *Env {
+	e.profile = profile
+	return e
+}
+
+//Profile 返回当前生效的profile
+func (e *Env) Profile() string {
+	if e.profile != "" {
+		return e.profile
+	}
+	v, _, _ := e.lookup(ProfileVar, "")
+	return v
+}
+
+//profileKey APP_REDIS_ADDR在prod下为APP_REDIS_ADDR__PROD
+func profileKey(key, profile string) string {
+	return key + "__" + upper(profile)
+}
+
+//ProfilePath 在扩展名前插入profile，例如config.env在prod下为config.prod.env
+func ProfilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+//ProfileFiles 按顺序加载配置文件（.env为dotenv，.json/.yaml/.yml为结构化文件），
+//profile不为空且存在对应的profile文件时，profile文件紧跟在基础文件后面，优先级更高
+func ProfileFiles(profile string, paths ...string) ([]Source, error) {
+	var sources []Source
+	for _, p := range paths {
+		s, err := fileSource(p)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+		if profile == "" {
+			continue
+		}
+		pp := ProfilePath(p, profile)
+		if !Exist(pp) {
+			continue
+		}
+		s, err = fileSource(pp)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	return sources, nil
+}
+
+func fileSource(path string) (*MapSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return ConfigFile(path)
+	}
+	return DotEnvFile(path)
+}
+
+//isRequired 设置了profile标签时，只在列出的profile下必填，例如profile:"prod,test"，
+//否则按require标签判断
+func isRequired(sf reflect.StructField, profile string) (bool, error) {
+	ps, ok := sf.Tag.Lookup("profile")
+	if !ok {
+		return parseBool(sf.Tag.Get("require"))
+	}
+	for _, p := range strings.Split(ps, ",") {
+		if p = strings.TrimSpace(p); p != "" && strings.EqualFold(p, profile) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
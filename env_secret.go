@@ -0,0 +1,175 @@
+package misc
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const mask = "******"
+
+//resolveRef 解析引用形式的值：file://路径 读取文件内容（去掉末尾换行），base64:内容 解码，
+//字段设置resolve:"false"时不解析
+func resolveRef(ev string, sf reflect.StructField) (string, error) {
+	if s, ok := sf.Tag.Lookup("resolve"); ok {
+		on, err := parseBool(s)
+		if err != nil {
+			return "", err
+		}
+		if !on {
+			return ev, nil
+		}
+	}
+	switch {
+	case strings.HasPrefix(ev, "file://"):
+		b, err := ioutil.ReadFile(strings.TrimPrefix(ev, "file://"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(ev, "base64:"):
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ev, "base64:"))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return ev, nil
+}
+
+func isSecret(sf reflect.StructField) bool {
+	on, _ := parseBool(sf.Tag.Get("secret"))
+	return on
+}
+
+//Dump 以"字段路径 = 值"的形式输出配置，每行一个字段，secret:"true"的字段输出******，
+//URL中的密码总是被隐藏
+func Dump(v interface{}) string {
+	return env.Dump(v)
+}
+
+//Dump ....
+func (e *Env) Dump(v interface{}) string {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Sprintf("%v", v)
+	}
+	var lines []string
+	dump("", rv, &lines)
+	return strings.Join(lines, "\n")
+}
+
+func dump(path string, ind reflect.Value, lines *[]string) {
+	for i := 0; i < ind.NumField(); i++ {
+		sf := ind.Type().Field(i)
+		if skipField(sf) {
+			continue
+		}
+		p := sf.Name
+		if _, inline := parseEnvTag(sf); inline {
+			p = path
+		} else if path != "" {
+			p = path + "." + sf.Name
+		}
+		fv := ind.Field(i)
+		if _, ok := indexElem(sf.Type); ok {
+			for j := 0; j < fv.Len(); j++ {
+				item := reflect.Indirect(fv.Index(j))
+				if !item.IsValid() {
+					continue
+				}
+				dump(fmt.Sprintf("%s[%d]", p, j), item, lines)
+			}
+			continue
+		}
+		if isNested(sf.Type) {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					*lines = append(*lines, p+" = <nil>")
+					continue
+				}
+				fv = fv.Elem()
+			}
+			dump(p, fv, lines)
+			continue
+		}
+		val := formatValue(fv, sf)
+		switch {
+		case isSecret(sf) && val != "":
+			val = mask
+		default:
+			val = redactURL(val)
+		}
+		*lines = append(*lines, p+" = "+val)
+	}
+}
+
+//redactURL 隐藏URL中的密码，不是URL或者没有密码时原样返回
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), "MASK")
+	return strings.Replace(u.String(), ":MASK@", ":"+mask+"@", 1)
+}
+
+//formatValue 把字段的值格式化为Fill可以解析的字符串
+func formatValue(v reflect.Value, sf reflect.StructField) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		return x.String()
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case url.URL:
+		return x.String()
+	case net.IPNet:
+		return x.String()
+	case encoding.TextMarshaler:
+		if b, err := x.MarshalText(); err == nil {
+			return string(b)
+		}
+	case fmt.Stringer:
+		return x.String()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatValue(v.Index(i), sf)
+		}
+		return strings.Join(items, sliceSep(sf, ";"))
+	case reflect.Map:
+		kvSep := "="
+		if s, ok := sf.Tag.Lookup("kv_sep"); ok && s != "" {
+			kvSep = s
+		}
+		keys := v.MapKeys()
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = k.String() + kvSep + formatValue(v.MapIndex(k), sf)
+		}
+		sort.Strings(items)
+		return strings.Join(items, sliceSep(sf, ","))
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,270 @@
+package misc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//Source 配置来源，Lookup的key为完整的环境变量名，例如MISC_REDIS_ADDR
+type Source interface {
+	Name() string
+	Lookup(key string) (string, bool)
+}
+
+type osSource struct{}
+
+//OSEnv 进程环境变量
+func OSEnv() Source {
+	return osSource{}
+}
+
+func (osSource) Name() string {
+	return "env"
+}
+
+func (osSource) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+//Reloader 可以重新加载的配置来源，Reload返回内容是否发生了变化
+type Reloader interface {
+	Reload() (bool, error)
+}
+
+//MapSource 以map保存的配置来源，key为完整的环境变量名
+type MapSource struct {
+	name string
+	lock sync.RWMutex
+	vals map[string]string
+
+	path    string
+	load    func(path string) (map[string]string, error)
+	modTime time.Time
+	size    int64
+}
+
+//Overrides 显式覆盖的配置值
+func Overrides(vals map[string]string) *MapSource {
+	return NewMapSource("override", vals)
+}
+
+//NewMapSource ....
+func NewMapSource(name string, vals map[string]string) *MapSource {
+	m := make(map[string]string, len(vals))
+	for k, v := range vals {
+		m[k] = v
+	}
+	return &MapSource{name: name, vals: m}
+}
+
+//Name ....
+func (s *MapSource) Name() string {
+	return s.name
+}
+
+//Lookup ....
+func (s *MapSource) Lookup(key string) (string, bool) {
+	s.lock.RLock()
+	v, ok := s.vals[key]
+	s.lock.RUnlock()
+	return v, ok
+}
+
+//Keys 返回全部key，已排序
+func (s *MapSource) Keys() []string {
+	s.lock.RLock()
+	keys := make([]string, 0, len(s.vals))
+	for k := range s.vals {
+		keys = append(keys, k)
+	}
+	s.lock.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+//Path 文件来源的路径，非文件来源返回""
+func (s *MapSource) Path() string {
+	return s.path
+}
+
+//Reload 文件的修改时间或大小变化时重新读取文件，非文件来源直接返回false
+func (s *MapSource) Reload() (bool, error) {
+	if s.load == nil {
+		return false, nil
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false, err
+	}
+	s.lock.RLock()
+	same := fi.ModTime().Equal(s.modTime) && fi.Size() == s.size
+	s.lock.RUnlock()
+	if same {
+		return false, nil
+	}
+	vals, err := s.load(s.path)
+	if err != nil {
+		return false, err
+	}
+	s.lock.Lock()
+	s.vals = vals
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	s.lock.Unlock()
+	return true, nil
+}
+
+func newFileSource(name, path string, load func(string) (map[string]string, error)) (*MapSource, error) {
+	s := &MapSource{name: name + path, path: path, load: load}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//DotEnvFile 读取.env文件，支持#注释、export前缀以及单双引号
+func DotEnvFile(path string) (*MapSource, error) {
+	return newFileSource("dotenv:", path, loadDotEnv)
+}
+
+func loadDotEnv(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := parseDotEnv(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", path, err)
+	}
+	return vals, nil
+}
+
+func parseDotEnv(b []byte) (map[string]string, error) {
+	vals := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: missing '='", n)
+		}
+		k := strings.TrimSpace(line[:i])
+		v := strings.TrimSpace(line[i+1:])
+		switch {
+		case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+			uv, err := strconv.Unquote(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d:%s", n, err)
+			}
+			v = uv
+		case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+			v = v[1 : len(v)-1]
+		default:
+			if j := strings.Index(v, " #"); j >= 0 {
+				v = strings.TrimSpace(v[:j])
+			}
+		}
+		vals[k] = v
+	}
+	return vals, sc.Err()
+}
+
+//ConfigFile 读取JSON或YAML配置文件（按扩展名判断），嵌套的key以_连接并转为大写，
+//例如{"misc":{"redis":{"addr":"..."}}}对应MISC_REDIS_ADDR。标量数组以;连接，
+//对象数组按下标展开，例如{"app":{"brokers":[{"url":"..."}]}}对应APP_BROKERS_0_URL
+func ConfigFile(path string) (*MapSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file type", path)
+	}
+	return newFileSource("file:", path, loadConfigFile)
+}
+
+func loadConfigFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file type", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", path, err)
+	}
+	vals := map[string]string{}
+	flatten("", doc, vals)
+	return vals, nil
+}
+
+func flatten(prefix string, v interface{}, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range t {
+			flatten(combine(prefix, upper(k), defaultSep, false), vv, out)
+		}
+	case map[interface{}]interface{}:
+		for k, vv := range t {
+			flatten(combine(prefix, upper(fmt.Sprint(k)), defaultSep, false), vv, out)
+		}
+	case []interface{}:
+		//元素为对象时按PREFIX_0_KEY展开，对应struct slice；标量数组用;连接
+		if hasObject(t) {
+			for i, vv := range t {
+				flatten(indexName(prefix, i), vv, out)
+			}
+			return
+		}
+		items := make([]string, len(t))
+		for i, vv := range t {
+			items[i] = scalarString(vv)
+		}
+		out[prefix] = strings.Join(items, ";")
+	default:
+		out[prefix] = scalarString(t)
+	}
+}
+
+func hasObject(items []interface{}) bool {
+	for _, v := range items {
+		switch v.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+func scalarString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
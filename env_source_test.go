@@ -0,0 +1,62 @@
+package misc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFileArrays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.yaml")
+	doc := `
+app:
+  tags: [a, b, 1.5]
+  brokers:
+    - url: amqp://a
+      weight: 2
+    - url: amqp://b
+`
+	if err := ioutil.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"APP_TAGS":             "a;b;1.5",
+		"APP_BROKERS_0_URL":    "amqp://a",
+		"APP_BROKERS_0_WEIGHT": "2",
+		"APP_BROKERS_1_URL":    "amqp://b",
+	}
+	for k, v := range want {
+		if got, ok := src.Lookup(k); !ok || got != v {
+			t.Errorf("%s = %q, %v, want %q", k, got, ok, v)
+		}
+	}
+
+	var c struct {
+		Tags    []string
+		Brokers []struct {
+			URL    string
+			Weight int `default:"1"`
+		}
+	}
+	if err := NewEnv(src).Fill("APP", &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", "b", "1.5"}) {
+		t.Fatalf("Tags = %v", c.Tags)
+	}
+	if len(c.Brokers) != 2 || c.Brokers[0].URL != "amqp://a" || c.Brokers[0].Weight != 2 ||
+		c.Brokers[1].URL != "amqp://b" || c.Brokers[1].Weight != 1 {
+		t.Fatalf("Brokers = %+v", c.Brokers)
+	}
+}
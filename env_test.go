@@ -0,0 +1,71 @@
+package misc
+
+import (
+	"testing"
+)
+
+type recConfig struct {
+	Name     string
+	Next     *recConfig
+	Children []recConfig
+}
+
+type sectionConfig struct {
+	Addr  string
+	Cache *struct {
+		Size int `default:"10"`
+	}
+}
+
+func TestFillSelfReferencingPointer(t *testing.T) {
+	e := NewEnv(Overrides(map[string]string{
+		"APP_NAME":                 "a",
+		"APP_NEXT_NAME":            "b",
+		"APP_CHILDREN_0_NAME":      "c",
+		"APP_CHILDREN_0_NEXT_NAME": "d",
+	}))
+	var c recConfig
+	if err := e.Fill("APP", &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "a" || c.Next == nil || c.Next.Name != "b" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	if c.Next.Next != nil {
+		t.Fatalf("APP_NEXT_NEXT should stay nil, got %+v", c.Next.Next)
+	}
+	if len(c.Children) != 1 || c.Children[0].Name != "c" || c.Children[0].Next == nil || c.Children[0].Next.Name != "d" {
+		t.Fatalf("unexpected children %+v", c.Children)
+	}
+}
+
+func TestFillNilSectionNotConfigured(t *testing.T) {
+	var c sectionConfig
+	if err := NewEnv(Overrides(map[string]string{"APP_ADDR": "x"})).Fill("APP", &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cache != nil {
+		t.Fatalf("Cache should stay nil when none of its variables is set, got %+v", c.Cache)
+	}
+	if err := NewEnv(Overrides(map[string]string{"APP_CACHE_SIZE": "20"})).Fill("APP", &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cache == nil || c.Cache.Size != 20 {
+		t.Fatalf("Cache = %+v, want Size 20", c.Cache)
+	}
+}
+
+func TestDescribeSelfReferencingType(t *testing.T) {
+	vars, err := Describe("APP", recConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	want := []string{"APP_NAME"}
+	if len(names) != len(want) || names[0] != want[0] {
+		t.Fatalf("Describe = %v, want %v", names, want)
+	}
+}
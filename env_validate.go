@@ -0,0 +1,241 @@
+package misc
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//FieldError 单个变量的错误，Rule为出错的规则，例如require、parse、min、max、oneof、regexp、len、url
+type FieldError struct {
+	Name string
+	Rule string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Name, e.Rule, e.Err)
+}
+
+//FieldErrors Fill收集到的全部错误
+type FieldErrors []*FieldError
+
+func (es FieldErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+	return fmt.Sprintf("%d errors:\n\t%s", len(msgs), strings.Join(msgs, "\n\t"))
+}
+
+//validateRules 按顺序检查的校验标签
+var validateRules = []struct {
+	tag   string
+	check func(v reflect.Value, arg string) error
+}{
+	{"min", checkMin},
+	{"max", checkMax},
+	{"len", checkLen},
+	{"oneof", checkOneOf},
+	{"regexp", checkRegexp},
+	{"url", checkURL},
+}
+
+//validate 检查解析后的值，nil指针不检查
+func validate(name string, f reflect.Value, sf reflect.StructField, st *fillState) {
+	for f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return
+		}
+		f = f.Elem()
+	}
+	for _, r := range validateRules {
+		arg, ok := sf.Tag.Lookup(r.tag)
+		if !ok {
+			continue
+		}
+		if err := r.check(f, arg); err != nil {
+			st.fail(name, r.tag, err)
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//compare 比较数值（或字符串、slice、map的长度）与标签参数，返回-1、0、1
+func compare(v reflect.Value, arg string) (int, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var b int64
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return 0, err
+			}
+			b = int64(d)
+		} else {
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			b = n
+		}
+		return cmpInt64(v.Int(), b), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		a := v.Uint()
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.Float32, reflect.Float64:
+		b, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return 0, err
+		}
+		a := v.Float()
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String, reflect.Slice, reflect.Map:
+		b, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return cmpInt64(int64(v.Len()), b), nil
+	}
+	return 0, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func checkMin(v reflect.Value, arg string) error {
+	c, err := compare(v, arg)
+	if err != nil {
+		return err
+	}
+	if c < 0 {
+		return fmt.Errorf("%s is less than %s", display(v), arg)
+	}
+	return nil
+}
+
+func checkMax(v reflect.Value, arg string) error {
+	c, err := compare(v, arg)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return fmt.Errorf("%s is greater than %s", display(v), arg)
+	}
+	return nil
+}
+
+func checkLen(v reflect.Value, arg string) error {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return err
+	}
+	if v.Len() != n {
+		return fmt.Errorf("length %d is not %d", v.Len(), n)
+	}
+	return nil
+}
+
+//checkOneOf 候选值以空格分隔，例如oneof:"debug info warn error"
+func checkOneOf(v reflect.Value, arg string) error {
+	s := display(v)
+	for _, c := range strings.Fields(arg) {
+		if s == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not one of [%s]", s, arg)
+}
+
+var (
+	regexpLock  sync.Mutex
+	regexpCache = map[string]*regexp.Regexp{}
+)
+
+//checkRegexp 整个值必须匹配
+func checkRegexp(v reflect.Value, arg string) error {
+	regexpLock.Lock()
+	re, ok := regexpCache[arg]
+	if !ok {
+		var err error
+		re, err = regexp.Compile("^(?:" + arg + ")$")
+		if err != nil {
+			regexpLock.Unlock()
+			return err
+		}
+		regexpCache[arg] = re
+	}
+	regexpLock.Unlock()
+	s := display(v)
+	if !re.MatchString(s) {
+		return fmt.Errorf("%s does not match %s", s, arg)
+	}
+	return nil
+}
+
+//checkURL url:"true"时值必须是带scheme和host的URL
+func checkURL(v reflect.Value, arg string) error {
+	on, err := parseBool(arg)
+	if err != nil || !on {
+		return err
+	}
+	s := display(v)
+	if u, ok := v.Interface().(url.URL); ok {
+		s = u.String()
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s is not an absolute url", s)
+	}
+	return nil
+}
+
+func display(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,210 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+//ChangeFunc 配置变化回调，old、new为指向配置struct的指针，changed为发生变化的变量名
+type ChangeFunc func(old, new interface{}, changed []string)
+
+//Watcher 监听配置变化，每次变化都会重新Fill出一份新的配置并原子替换
+type Watcher struct {
+	env      *Env
+	prefix   string
+	typ      reflect.Type
+	onChange ChangeFunc
+	//base 传给Watch的struct在第一次Fill之前的副本，每次重新Fill都从它开始，保留调用方预设的值
+	base reflect.Value
+
+	lock    sync.Mutex
+	current atomic.Value
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type watchSnapshot struct {
+	v      interface{}
+	values map[string]string
+}
+
+//Watch 填充v并开始监听配置变化，收到SIGHUP或者文件来源发生变化时，重新Fill到一份新的配置中，
+//有变量变化时调用onChange。v只在Watch时填充一次，之后的配置通过Load或者onChange获取
+func (e *Env) Watch(prefix string, v interface{}, onChange ChangeFunc) (*Watcher, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("only the pointer to a struct is supported")
+	}
+	base := deepCopy(rv.Elem())
+	st := e.run(prefix, rv.Elem())
+	if err := st.err(); err != nil {
+		return nil, err
+	}
+	cp := reflect.New(rv.Elem().Type())
+	cp.Elem().Set(deepCopy(rv.Elem()))
+	w := &Watcher{
+		env:      e,
+		prefix:   prefix,
+		typ:      rv.Elem().Type(),
+		onChange: onChange,
+		base:     base,
+		stop:     make(chan struct{}),
+	}
+	w.current.Store(&watchSnapshot{v: cp.Interface(), values: st.values})
+	go w.loop()
+	return w, nil
+}
+
+//Load 返回当前配置，类型与传给Watch的指针相同，调用方不要修改返回的值
+func (w *Watcher) Load() interface{} {
+	return w.current.Load().(*watchSnapshot).v
+}
+
+//Reload 重新加载全部文件来源并重新Fill，返回发生变化的变量名
+func (w *Watcher) Reload() ([]string, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, s := range w.env.sources {
+		if r, ok := s.(Reloader); ok {
+			if _, err := r.Reload(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	nv := reflect.New(w.typ)
+	nv.Elem().Set(deepCopy(w.base))
+	st := w.env.run(w.prefix, nv.Elem())
+	if err := st.err(); err != nil {
+		return nil, err
+	}
+	old := w.current.Load().(*watchSnapshot)
+	changed := diffValues(old.values, st.values)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	w.current.Store(&watchSnapshot{v: nv.Interface(), values: st.values})
+	if w.onChange != nil {
+		w.onChange(old.v, nv.Interface(), changed)
+	}
+	return changed, nil
+}
+
+//Stop 停止监听
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *Watcher) loop() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	interval := w.env.interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !w.filesChanged() {
+				continue
+			}
+		}
+		if _, err := w.Reload(); err != nil {
+			log.Println("env watch", w.prefix, err)
+		}
+	}
+}
+
+//filesChanged 检查文件来源是否变化，只比较文件的修改时间和大小
+func (w *Watcher) filesChanged() bool {
+	for _, s := range w.env.sources {
+		ms, ok := s.(*MapSource)
+		if !ok || ms.path == "" {
+			continue
+		}
+		fi, err := os.Stat(ms.path)
+		if err != nil {
+			continue
+		}
+		ms.lock.RLock()
+		changed := !fi.ModTime().Equal(ms.modTime) || fi.Size() != ms.size
+		ms.lock.RUnlock()
+		if changed {
+			return true
+		}
+	}
+	return false
+}
+
+func diffValues(old, new map[string]string) []string {
+	var changed []string
+	for k, v := range new {
+		if ov, ok := old[k]; !ok || ov != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+//deepCopy 复制v，指针、slice、map指向新的副本，避免重新Fill时修改之前的配置。非导出字段只做浅拷贝
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(deepCopy(v.Elem()))
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := cp.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return cp
+	}
+	return v
+}
@@ -0,0 +1,80 @@
+package misc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	Addr  string
+	Size  int `default:"1"`
+	Keep  string
+	Extra *struct {
+		Name string
+	}
+}
+
+func TestWatchFileReloadKeepsPresetValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.env")
+	if err := ioutil.WriteFile(path, []byte("APP_ADDR=a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := DotEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEnv(src).WithWatchInterval(10 * time.Millisecond)
+
+	type change struct {
+		old, new *watchConfig
+		changed  []string
+	}
+	changes := make(chan change, 1)
+	c := watchConfig{Keep: "preset"}
+	w, err := e.Watch("APP", &c, func(old, new interface{}, changed []string) {
+		changes <- change{old.(*watchConfig), new.(*watchConfig), changed}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if c.Addr != "a" || c.Keep != "preset" {
+		t.Fatalf("initial fill = %+v", c)
+	}
+
+	// 修改内容和大小，保证修改时间或大小变化能被检测到
+	time.Sleep(20 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte("APP_ADDR=bb\nAPP_EXTRA_NAME=x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var ch change
+	select {
+	case ch = <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+	if ch.new.Addr != "bb" || ch.new.Keep != "preset" || ch.new.Size != 1 {
+		t.Fatalf("reloaded config = %+v", ch.new)
+	}
+	if ch.new.Extra == nil || ch.new.Extra.Name != "x" {
+		t.Fatalf("reloaded Extra = %+v", ch.new.Extra)
+	}
+	if ch.old.Addr != "a" || ch.old.Extra != nil {
+		t.Fatalf("old config modified by reload: %+v", ch.old)
+	}
+	if want := []string{"APP_ADDR", "APP_EXTRA_NAME"}; !reflect.DeepEqual(ch.changed, want) {
+		t.Fatalf("changed = %v, want %v", ch.changed, want)
+	}
+	if got := w.Load().(*watchConfig); got != ch.new {
+		t.Fatalf("Load returned %+v, want the new config", got)
+	}
+}
@@ -9,4 +9,5 @@ require (
 	github.com/pborman/uuid v1.2.0
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 	github.com/syndtr/goleveldb v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
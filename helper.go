@@ -16,7 +16,7 @@ func Exist(filename string) bool {
 	return err == nil || os.IsExist(err)
 }
 
-//UUID ....
+//UUID 去掉连字符的UUIDv1，需要按时间排序时使用UUIDv7或ULID
 func UUID() string {
 	return strings.Replace(uuid.NewUUID().String(), "-", "", -1)
 }
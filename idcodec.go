@@ -0,0 +1,157 @@
+package misc
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	//crockford32Alphabet Crockford base32，后5个字符只用作校验符
+	crockford32Alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ*~$=U"
+	//sortableWidth 62^11 > 2^64
+	sortableWidth = 11
+)
+
+var (
+	//ErrIDSyntax 含有不合法的字符
+	ErrIDSyntax = errors.New("invalid character")
+	//ErrIDRange 超出uint64范围
+	ErrIDRange = errors.New("value out of range")
+	//ErrIDLength 长度不对
+	ErrIDLength = errors.New("invalid length")
+	//ErrIDChecksum 校验符不匹配
+	ErrIDChecksum = errors.New("checksum mismatch")
+)
+
+//IDCodecError 解析编码后的ID出错，Err为ErrIDSyntax、ErrIDRange、ErrIDLength或ErrIDChecksum
+type IDCodecError struct {
+	Encoding string
+	Input    string
+	Err      error
+}
+
+func (e *IDCodecError) Error() string {
+	return "misc: parsing " + e.Encoding + " id " + `"` + e.Input + `": ` + e.Err.Error()
+}
+
+//Unwrap ....
+func (e *IDCodecError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	base62Index = alphabetIndex(base62Alphabet)
+	base32Index = func() [256]int8 {
+		idx := alphabetIndex(crockford32Alphabet[:32])
+		//Crockford允许的容错字符
+		for c, v := range map[byte]int8{'O': 0, 'I': 1, 'L': 1} {
+			idx[c] = v
+		}
+		for c := 'a'; c <= 'z'; c++ {
+			idx[c] = idx[c-'a'+'A']
+		}
+		return idx
+	}()
+)
+
+func alphabetIndex(alphabet string) [256]int8 {
+	var idx [256]int8
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		idx[alphabet[i]] = int8(i)
+	}
+	return idx
+}
+
+func encode(id uint64, alphabet string, width int) string {
+	base := uint64(len(alphabet))
+	var buf [64]byte
+	i := len(buf)
+	for id > 0 || i == len(buf) {
+		i--
+		buf[i] = alphabet[id%base]
+		id /= base
+	}
+	for len(buf)-i < width {
+		i--
+		buf[i] = alphabet[0]
+	}
+	return string(buf[i:])
+}
+
+func decode(s, encoding string, index *[256]int8, base uint64) (uint64, error) {
+	if s == "" {
+		return 0, &IDCodecError{encoding, s, ErrIDLength}
+	}
+	var id uint64
+	for i := 0; i < len(s); i++ {
+		v := index[s[i]]
+		if v < 0 {
+			return 0, &IDCodecError{encoding, s, ErrIDSyntax}
+		}
+		if id > (^uint64(0)-uint64(v))/base {
+			return 0, &IDCodecError{encoding, s, ErrIDRange}
+		}
+		id = id*base + uint64(v)
+	}
+	return id, nil
+}
+
+//FormatIDBase62 base62编码，最短的URL安全形式
+func FormatIDBase62(id uint64) string {
+	return encode(id, base62Alphabet, 0)
+}
+
+//ParseIDBase62 ....
+func ParseIDBase62(s string) (uint64, error) {
+	return decode(s, "base62", &base62Index, 62)
+}
+
+//FormatIDSortable 固定11位的base62编码，字典序与数值顺序一致
+func FormatIDSortable(id uint64) string {
+	return encode(id, base62Alphabet, sortableWidth)
+}
+
+//ParseIDSortable ....
+func ParseIDSortable(s string) (uint64, error) {
+	if len(s) != sortableWidth {
+		return 0, &IDCodecError{"sortable", s, ErrIDLength}
+	}
+	id, err := decode(s, "sortable", &base62Index, 62)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//FormatIDBase32 Crockford base32编码，末尾带一位模37校验符，不区分大小写，适合人工录入
+func FormatIDBase32(id uint64) string {
+	return encode(id, crockford32Alphabet[:32], 0) + string(crockford32Alphabet[id%37])
+}
+
+//ParseIDBase32 忽略连字符，O按0、I和L按1处理，并检查校验符
+func ParseIDBase32(s string) (uint64, error) {
+	in := s
+	s = strings.Replace(s, "-", "", -1)
+	if len(s) < 2 {
+		return 0, &IDCodecError{"base32", in, ErrIDLength}
+	}
+	id, err := decode(s[:len(s)-1], "base32", &base32Index, 32)
+	if err != nil {
+		err.(*IDCodecError).Input = in
+		return 0, err
+	}
+	check := strings.ToUpper(s[len(s)-1:])
+	if check == "O" {
+		check = "0"
+	} else if check == "I" || check == "L" {
+		check = "1"
+	}
+	if strings.Index(crockford32Alphabet, check) != int(id%37) {
+		return 0, &IDCodecError{"base32", in, ErrIDChecksum}
+	}
+	return id, nil
+}
@@ -3,118 +3,294 @@ package misc
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+//RollbackPolicy 时钟回拨时的处理策略
+type RollbackPolicy int
+
 const (
-	maxPrefix    = 2<<3 - 1
-	maxNode      = 2<<7 - 1
-	maxSeq       = 2<<19 - 1
-	maxTimestamp = 2<<31 - 1
-	epoch        = int64(1575129600) //second
+	//RollbackWait 等待时钟追上上次的时间戳，回拨超过maxWait时返回错误
+	RollbackWait RollbackPolicy = iota
+	//RollbackBorrow 继续使用上次的时间戳，序列号用完后借用下一秒，借用超过maxWait时返回错误
+	RollbackBorrow
+	//RollbackError 立即返回错误
+	RollbackError
 )
 
-//IDGenerator ID发号器
+//ErrClockRollback 发生时钟回拨
+var ErrClockRollback = errors.New("发生时钟回拨")
+
+//IDGenerator ID发号器，state把上次的时间戳（相对Epoch）和序列号打包在一个uint64中，通过CAS更新
 type IDGenerator struct {
-	prefix uint
-	nodeID uint
-	last   int64
-	seq    uint64
-	step   uint64
-	lock   sync.Mutex
+	rollbacks uint64
+	state     uint64
+	reserved  int64
+	behind    uint32
+	layout    Layout
+	epoch     int64
+	prefix    uint
+	nodeID    uint
+	step      uint64
+	policy    RollbackPolicy
+	maxWait   time.Duration
+	lease     *nodeLease
+	store     StateStore
+	ahead     int64
+	saveLock  sync.Mutex
+}
+
+//IDGeneratorOptions 发号器的完整配置
+type IDGeneratorOptions struct {
+	//Layout 零值时使用DefaultLayout
+	Layout Layout
+	Prefix uint
+	//Node Lease为空时使用的节点号
+	Node uint
+	//Lease 不为空时从中自动分配节点号
+	Lease LeaseStore
+	//State 不为空时持久化已经使用的时间戳，启动时不再等待一个时间单位，
+	//而是检查时钟是否晚于上次保存的时间
+	State StateStore
+	//StateAhead 每次持久化时预留的时长，默认1秒
+	StateAhead time.Duration
+	//MaxStartupWait 启动时时钟早于保存的时间时最多等待的时长，超过返回ErrClockRollback，
+	//默认为StateAhead的2倍，小于StateAhead时按StateAhead处理
+	MaxStartupWait time.Duration
 }
 
-//NewIDGenerator ....
+//NewIDGenerator 使用DefaultLayout创建发号器
 func NewIDGenerator(prefix, node uint) *IDGenerator {
-	if prefix > maxPrefix {
-		panic(fmt.Errorf("prefix不合法，prefix不能大于%d", maxPrefix))
+	return NewIDGeneratorWithLayout(DefaultLayout, prefix, node)
+}
+
+//NewIDGeneratorWithLayout 使用指定的布局创建发号器
+func NewIDGeneratorWithLayout(layout Layout, prefix, node uint) *IDGenerator {
+	p, err := NewIDGeneratorWithOptions(IDGeneratorOptions{Layout: layout, Prefix: prefix, Node: node})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+//NewIDGeneratorWithOptions 按配置创建发号器，使用了Lease或State时不再使用后调用Close
+func NewIDGeneratorWithOptions(opts IDGeneratorOptions) (*IDGenerator, error) {
+	layout := opts.Layout
+	if layout == (Layout{}) {
+		layout = DefaultLayout
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	if uint64(opts.Prefix) > layout.MaxPrefix() {
+		return nil, fmt.Errorf("prefix不合法，prefix不能大于%d", layout.MaxPrefix())
 	}
-	if node > maxNode {
-		panic(fmt.Errorf("node不合法，node不能大于%d", maxNode))
+	p := &IDGenerator{
+		layout:  layout,
+		epoch:   layout.tick(layout.Epoch),
+		prefix:  opts.Prefix,
+		nodeID:  opts.Node,
+		step:    1,
+		policy:  RollbackWait,
+		maxWait: time.Second,
 	}
-	time.Sleep(time.Second)
-	return &IDGenerator{
-		prefix: prefix,
-		nodeID: node,
-		last:   time.Now().Unix(),
-		step:   1,
+	if opts.Lease != nil {
+		lease, err := acquireLease(opts.Lease, opts.Prefix, uint(layout.MaxNode()))
+		if err != nil {
+			return nil, err
+		}
+		p.lease = lease
+		p.nodeID = lease.node
+	} else if uint64(opts.Node) > layout.MaxNode() {
+		return nil, fmt.Errorf("node不合法，node不能大于%d", layout.MaxNode())
 	}
+	if opts.State == nil {
+		time.Sleep(layout.Unit)
+	} else if err := p.restore(opts); err != nil {
+		if p.lease != nil {
+			_ = p.lease.close()
+		}
+		return nil, err
+	}
+	p.state = p.pack(layout.tick(time.Now())-p.epoch, 0)
+	return p, nil
+}
+
+//Close 停止续约并释放节点号，保存最后使用的时间戳
+func (p *IDGenerator) Close() error {
+	var err error
+	if p.store != nil {
+		err = p.save()
+	}
+	if p.lease != nil {
+		if lerr := p.lease.close(); err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
+
+func (p *IDGenerator) pack(ts int64, seq uint64) uint64 {
+	return uint64(ts)<<p.layout.SeqBits | seq
+}
+
+func (p *IDGenerator) unpack(state uint64) (int64, uint64) {
+	return int64(state >> p.layout.SeqBits), state & p.layout.MaxSeq()
+}
+
+//Layout 返回发号器使用的布局
+func (p *IDGenerator) Layout() Layout {
+	return p.layout
 }
 
 //WithStep 设置步长
 func (p *IDGenerator) WithStep(step uint64) *IDGenerator {
-	if step == 0 || step > maxSeq {
-		panic(fmt.Errorf("步长必须在[1-%d]之间", maxSeq))
+	if step == 0 || step > p.layout.MaxSeq() {
+		panic(fmt.Errorf("步长必须在[1-%d]之间", p.layout.MaxSeq()))
 	}
 	p.step = step
 	return p
 }
 
-//NextID 获取下一个ID
+//WithRollback 设置时钟回拨策略，默认RollbackWait，maxWait为1秒
+func (p *IDGenerator) WithRollback(policy RollbackPolicy, maxWait time.Duration) *IDGenerator {
+	p.policy = policy
+	p.maxWait = maxWait
+	return p
+}
+
+//Rollbacks 发生时钟回拨的次数，连续的回拨只计一次
+func (p *IDGenerator) Rollbacks() uint64 {
+	return atomic.LoadUint64(&p.rollbacks)
+}
+
+//NextID 获取下一个ID，时钟回拨无法处理时panic
 func (p *IDGenerator) NextID() uint64 {
-	p.lock.Lock()
-	current := time.Now().Unix()
-	if current < p.last {
-		refused := p.last - current
-		p.lock.Unlock()
-		panic(fmt.Errorf("发生时钟回拨，拒绝执行%d秒", refused))
+	id, err := p.NextIDE()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+//NextIDE 获取下一个ID，时钟回拨按策略处理，无法处理时返回ErrClockRollback
+func (p *IDGenerator) NextIDE() (uint64, error) {
+	ts, seq, _, err := p.reserve(1)
+	if err != nil {
+		return 0, err
+	}
+	return p.layout.Compose(uint64(p.prefix), uint64(ts), uint64(p.nodeID), seq), nil
+}
+
+//NextIDs 一次获取n个ID，出错时panic
+func (p *IDGenerator) NextIDs(n int) []uint64 {
+	ids, err := p.NextIDsE(n)
+	if err != nil {
+		panic(err)
+	}
+	return ids
+}
+
+//NextIDsE 一次获取n个ID，n为0时返回空slice，小于0时返回错误。每次CAS预留当前时间单位内剩余的一段连续序列号，不够时在下一个时间单位继续预留
+func (p *IDGenerator) NextIDsE(n int) ([]uint64, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("ID数量不能小于0：%d", n)
 	}
-	if current > p.last {
-		p.seq = 0
-		p.last = current
+	ids := make([]uint64, 0, n)
+	for len(ids) < n {
+		ts, seq, count, err := p.reserve(uint64(n - len(ids)))
+		if err != nil {
+			return ids, err
+		}
+		for i := uint64(0); i < count; i++ {
+			ids = append(ids, p.layout.Compose(uint64(p.prefix), uint64(ts), uint64(p.nodeID), seq+i*p.step))
+		}
 	}
-	p.seq += p.step
-	if p.seq > maxSeq {
-		p.lock.Unlock()
-		return p.NextID()
+	return ids, nil
+}
+
+//reserve 预留最多want个序列号，返回时间戳、第一个序列号和预留的数量
+func (p *IDGenerator) reserve(want uint64) (int64, uint64, uint64, error) {
+	unit := p.layout.Unit
+	maxSeq := p.layout.MaxSeq()
+	for {
+		old := atomic.LoadUint64(&p.state)
+		last, seq := p.unpack(old)
+		now := time.Now()
+		if p.lease != nil && !p.lease.valid(now) {
+			return 0, 0, 0, ErrLeaseLost
+		}
+		current := p.layout.tick(now) - p.epoch
+		behind := current < last
+		if behind {
+			if atomic.CompareAndSwapUint32(&p.behind, 0, 1) {
+				atomic.AddUint64(&p.rollbacks, 1)
+			}
+			refused := time.Duration(last-current) * unit
+			if p.policy == RollbackError || refused > p.maxWait {
+				return 0, 0, 0, fmt.Errorf("%w，拒绝执行%s", ErrClockRollback, refused)
+			}
+			if p.policy == RollbackWait {
+				p.sleepUntil(current + 1)
+				continue
+			}
+		} else if atomic.LoadUint32(&p.behind) == 1 {
+			atomic.StoreUint32(&p.behind, 0)
+		}
+		ts := last
+		if current > last {
+			ts = current
+			seq = 0
+		}
+		avail := (maxSeq - seq) / p.step
+		if avail == 0 {
+			if !behind {
+				p.sleepUntil(current + 1)
+				continue
+			}
+			//借用下一个时间单位
+			ts++
+			seq = 0
+			avail = maxSeq / p.step
+		}
+		if ts < 0 || uint64(ts) > p.layout.MaxTimestamp() {
+			return 0, 0, 0, fmt.Errorf("时间戳%d超出布局范围", ts)
+		}
+		if p.store != nil && ts+p.epoch > atomic.LoadInt64(&p.reserved) {
+			if err := p.extend(ts + p.epoch); err != nil {
+				return 0, 0, 0, err
+			}
+			continue
+		}
+		count := want
+		if count > avail {
+			count = avail
+		}
+		if atomic.CompareAndSwapUint64(&p.state, old, p.pack(ts, seq+count*p.step)) {
+			return ts, seq + p.step, count, nil
+		}
 	}
-	timestamp := current - epoch
-	var v = uint64(p.prefix)<<60 | uint64(timestamp)<<28 | uint64(p.nodeID)<<20 | uint64(p.seq)
-	p.lock.Unlock()
-	return v
+}
+
+//sleepUntil 等待到相对Epoch的时间戳ts
+func (p *IDGenerator) sleepUntil(ts int64) {
+	time.Sleep(time.Until(time.Unix(0, (ts+p.epoch)*int64(p.layout.Unit))))
 }
 
 //State 获取当前内部状态
 func (p *IDGenerator) State() string {
-	return fmt.Sprintf("prefix=%d,node=%d,last=%d,seq=%d,step=%d", p.prefix, p.nodeID, p.last, p.seq, p.step)
+	last, seq := p.unpack(atomic.LoadUint64(&p.state))
+	return fmt.Sprintf("prefix=%d,node=%d,last=%d,seq=%d,step=%d,rollbacks=%d", p.prefix, p.nodeID, last+p.epoch, seq, p.step, p.Rollbacks())
 }
 
-const dataformat = "060102150405"
-
-//FormatID ....
+//FormatID 按DefaultLayout格式化ID
 func FormatID(id uint64) string {
-	seq := id & maxSeq
-	node := (id >> 20) & maxNode
-	timestamp := (id >> 28) & maxTimestamp
-	prefix := (id >> 60) & maxPrefix
-	return fmt.Sprintf("%d%s%03d%07d", prefix, time.Unix(0, (int64(timestamp)+epoch)*int64(time.Second)).Format(dataformat), node, seq)
+	return DefaultLayout.Format(id)
 }
 
-//ParserID ....
+//ParserID 按DefaultLayout解析FormatID生成的字符串
 func ParserID(id string) (uint64, error) {
-	l := len(id)
-	if l < 22 {
-		return 0, errors.New("ID不合法")
-	}
-	seq, err := strconv.ParseInt(id[l-7:], 10, 64)
-	if err != nil || seq > maxSeq {
-		return 0, errors.New("ID不合法")
-	}
-	node, err := strconv.ParseInt(id[l-10:l-7], 10, 64)
-	if err != nil || node > maxNode {
-		return 0, errors.New("ID不合法")
-	}
-	t, err := time.ParseInLocation(dataformat, id[l-22:l-10], time.Local)
-	if err != nil {
-		return 0, errors.New("ID不合法")
-	}
-	time := t.Unix()
-	biz, err := strconv.ParseInt(id[:l-22], 10, 64)
-	if err != nil || biz > maxPrefix {
-		return 0, errors.New("ID不合法")
-	}
-	timestamp := time - epoch
-	return uint64(biz)<<60 | uint64(timestamp)<<28 | uint64(node)<<20 | uint64(seq), nil
+	return DefaultLayout.Parse(id)
 }
@@ -0,0 +1,109 @@
+package misc
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNextIDConcurrentUnique(t *testing.T) {
+	for name, layout := range map[string]Layout{"default": DefaultLayout, "snowflake": SnowflakeLayout} {
+		t.Run(name, func(t *testing.T) {
+			g := NewIDGeneratorWithLayout(layout, 0, 1)
+			const workers, perWorker = 8, 20000
+			results := make([][]uint64, workers)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					ids := make([]uint64, 0, perWorker)
+					for len(ids) < perWorker {
+						if w%2 == 0 {
+							ids = append(ids, g.NextID())
+							continue
+						}
+						batch, err := g.NextIDsE(100)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						ids = append(ids, batch...)
+					}
+					results[w] = ids
+				}(w)
+			}
+			wg.Wait()
+			seen := make(map[uint64]bool, workers*perWorker)
+			for w, ids := range results {
+				for i, id := range ids {
+					if seen[id] {
+						t.Fatalf("duplicate id %d", id)
+					}
+					seen[id] = true
+					if i > 0 && id <= ids[i-1] {
+						t.Fatalf("worker %d: id %d not greater than previous %d", w, id, ids[i-1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNextIDsE(t *testing.T) {
+	g := NewIDGeneratorWithLayout(SnowflakeLayout, 0, 1)
+	if _, err := g.NextIDsE(-1); err == nil {
+		t.Fatal("NextIDsE(-1) should fail")
+	}
+	ids, err := g.NextIDsE(0)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("NextIDsE(0) = %v, %v", ids, err)
+	}
+	// 超过一个时间单位的序列号容量
+	n := int(SnowflakeLayout.MaxSeq()) * 2
+	ids, err = g.NextIDsE(n)
+	if err != nil || len(ids) != n {
+		t.Fatalf("NextIDsE(%d) returned %d ids, %v", n, len(ids), err)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("ids not increasing at %d", i)
+		}
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		layout Layout
+		prefix uint
+		node   uint
+	}{
+		{"default", DefaultLayout, 3, 5},
+		{"snowflake", SnowflakeLayout, 0, 1023},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := c.layout
+			ids := []uint64{
+				l.Compose(uint64(c.prefix), 0, 0, 0),
+				l.Compose(uint64(c.prefix), uint64(l.Timestamp(time.Now())), uint64(c.node), l.MaxSeq()),
+			}
+			g := NewIDGeneratorWithLayout(l, c.prefix, c.node)
+			ids = append(ids, g.NextIDs(10)...)
+			for _, id := range ids {
+				s := l.Format(id)
+				got, err := l.Parse(s)
+				if err != nil {
+					t.Fatalf("Parse(%q): %v", s, err)
+				}
+				if got != id {
+					t.Fatalf("Parse(Format(%d)) = %d, formatted %q", id, got, s)
+				}
+			}
+			if _, err := l.Parse("123"); err != ErrInvalidID {
+				t.Fatalf("Parse(short) = %v, want ErrInvalidID", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//Layout ID的位布局，从高位到低位依次为prefix、时间戳、节点号、序列号，
+//时间戳为从Epoch开始经过的Unit数
+type Layout struct {
+	Epoch      time.Time     `json:"epoch"`
+	Unit       time.Duration `json:"unit"`
+	PrefixBits uint          `json:"prefix_bits"`
+	TimeBits   uint          `json:"time_bits"`
+	NodeBits   uint          `json:"node_bits"`
+	SeqBits    uint          `json:"seq_bits"`
+}
+
+var (
+	//DefaultLayout 4位prefix、32位秒级时间戳（从2019-12-01开始）、8位节点号、20位序列号
+	DefaultLayout = Layout{
+		Epoch:      time.Unix(1575129600, 0),
+		Unit:       time.Second,
+		PrefixBits: 4,
+		TimeBits:   32,
+		NodeBits:   8,
+		SeqBits:    20,
+	}
+	//SnowflakeLayout 与Twitter Snowflake兼容：最高位为0、41位毫秒级时间戳（从1288834974657开始）、10位节点号、12位序列号
+	SnowflakeLayout = Layout{
+		Epoch:      time.Unix(0, 1288834974657*int64(time.Millisecond)),
+		Unit:       time.Millisecond,
+		PrefixBits: 0,
+		TimeBits:   41,
+		NodeBits:   10,
+		SeqBits:    12,
+	}
+)
+
+//ErrInvalidID ID不合法
+var ErrInvalidID = errors.New("ID不合法")
+
+//Validate 检查布局是否合法
+func (l Layout) Validate() error {
+	if l.Unit != time.Second && l.Unit != time.Millisecond {
+		return fmt.Errorf("时间单位只支持秒和毫秒")
+	}
+	if l.PrefixBits+l.TimeBits+l.NodeBits+l.SeqBits > 64 {
+		return fmt.Errorf("prefix、时间戳、节点号、序列号的位数之和不能大于64")
+	}
+	if l.TimeBits == 0 || l.SeqBits == 0 {
+		return fmt.Errorf("时间戳和序列号的位数不能为0")
+	}
+	if l.Epoch.IsZero() || l.Epoch.After(time.Now()) {
+		return fmt.Errorf("Epoch必须设置并且不能晚于当前时间")
+	}
+	return nil
+}
+
+func bitMask(bits uint) uint64 {
+	if bits >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<bits - 1
+}
+
+//MaxPrefix ....
+func (l Layout) MaxPrefix() uint64 {
+	return bitMask(l.PrefixBits)
+}
+
+//MaxTimestamp ....
+func (l Layout) MaxTimestamp() uint64 {
+	return bitMask(l.TimeBits)
+}
+
+//MaxNode ....
+func (l Layout) MaxNode() uint64 {
+	return bitMask(l.NodeBits)
+}
+
+//MaxSeq ....
+func (l Layout) MaxSeq() uint64 {
+	return bitMask(l.SeqBits)
+}
+
+//Compose 按布局组合ID，各部分超出范围时被截断
+func (l Layout) Compose(prefix, timestamp, node, seq uint64) uint64 {
+	return (prefix&l.MaxPrefix())<<(l.TimeBits+l.NodeBits+l.SeqBits) |
+		(timestamp&l.MaxTimestamp())<<(l.NodeBits+l.SeqBits) |
+		(node&l.MaxNode())<<l.SeqBits |
+		seq&l.MaxSeq()
+}
+
+//Decompose 按布局拆分ID
+func (l Layout) Decompose(id uint64) (prefix, timestamp, node, seq uint64) {
+	seq = id & l.MaxSeq()
+	node = (id >> l.SeqBits) & l.MaxNode()
+	timestamp = (id >> (l.NodeBits + l.SeqBits)) & l.MaxTimestamp()
+	prefix = (id >> (l.TimeBits + l.NodeBits + l.SeqBits)) & l.MaxPrefix()
+	return
+}
+
+//tick 时间t对应的Unix时间单位数
+func (l Layout) tick(t time.Time) int64 {
+	return t.UnixNano() / int64(l.Unit)
+}
+
+//Time 时间戳对应的时间
+func (l Layout) Time(timestamp uint64) time.Time {
+	return time.Unix(0, (l.tick(l.Epoch)+int64(timestamp))*int64(l.Unit))
+}
+
+//Timestamp 时间t对应的时间戳，早于Epoch时返回负数
+func (l Layout) Timestamp(t time.Time) int64 {
+	return l.tick(t) - l.tick(l.Epoch)
+}
+
+const dataformat = "060102150405"
+
+func digits(n uint64) int {
+	return len(strconv.FormatUint(n, 10))
+}
+
+func (l Layout) timeWidth() int {
+	if l.Unit == time.Millisecond {
+		return len(dataformat) + 3
+	}
+	return len(dataformat)
+}
+
+//Format 把ID格式化为 prefix + yyMMddHHmmss(毫秒布局再加3位毫秒) + 节点号 + 序列号 的十进制字符串，
+//时间为本地时间，节点号和序列号按最大值的位数补0，PrefixBits为0时没有prefix
+func (l Layout) Format(id uint64) string {
+	prefix, timestamp, node, seq := l.Decompose(id)
+	t := l.Time(timestamp)
+	ts := t.Format(dataformat)
+	if l.Unit == time.Millisecond {
+		ts += fmt.Sprintf("%03d", t.Nanosecond()/int(time.Millisecond))
+	}
+	p := ""
+	if l.PrefixBits > 0 {
+		p = strconv.FormatUint(prefix, 10)
+	}
+	return fmt.Sprintf("%s%s%0*d%0*d", p, ts, digits(l.MaxNode()), node, digits(l.MaxSeq()), seq)
+}
+
+//Parse Format的逆操作
+func (l Layout) Parse(id string) (uint64, error) {
+	sw := digits(l.MaxSeq())
+	nw := digits(l.MaxNode())
+	tw := l.timeWidth()
+	n := len(id)
+	if n < sw+nw+tw {
+		return 0, ErrInvalidID
+	}
+	seq, err := strconv.ParseUint(id[n-sw:], 10, 64)
+	if err != nil || seq > l.MaxSeq() {
+		return 0, ErrInvalidID
+	}
+	node, err := strconv.ParseUint(id[n-sw-nw:n-sw], 10, 64)
+	if err != nil || node > l.MaxNode() {
+		return 0, ErrInvalidID
+	}
+	tp := id[n-sw-nw-tw : n-sw-nw]
+	t, err := time.ParseInLocation(dataformat, tp[:len(dataformat)], time.Local)
+	if err != nil {
+		return 0, ErrInvalidID
+	}
+	if l.Unit == time.Millisecond {
+		ms, err := strconv.Atoi(tp[len(dataformat):])
+		if err != nil {
+			return 0, ErrInvalidID
+		}
+		t = t.Add(time.Duration(ms) * time.Millisecond)
+	}
+	timestamp := l.Timestamp(t)
+	if timestamp < 0 || uint64(timestamp) > l.MaxTimestamp() {
+		return 0, ErrInvalidID
+	}
+	var prefix uint64
+	pp := id[:n-sw-nw-tw]
+	if l.PrefixBits > 0 {
+		prefix, err = strconv.ParseUint(pp, 10, 64)
+		if err != nil || prefix > l.MaxPrefix() {
+			return 0, ErrInvalidID
+		}
+	} else if pp != "" {
+		return 0, ErrInvalidID
+	}
+	return l.Compose(prefix, uint64(timestamp), node, seq), nil
+}
+
+//IDInfo ID的各个组成部分
+type IDInfo struct {
+	ID        uint64    `json:"id"`
+	Prefix    uint64    `json:"prefix"`
+	Timestamp uint64    `json:"timestamp"`
+	Node      uint64    `json:"node"`
+	Seq       uint64    `json:"seq"`
+	UTC       time.Time `json:"utc"`
+	Local     time.Time `json:"local"`
+	Formatted string    `json:"formatted"`
+	Layout    Layout    `json:"layout"`
+}
+
+//InspectID 按DefaultLayout拆分ID
+func InspectID(id uint64) IDInfo {
+	return DefaultLayout.Inspect(id)
+}
+
+//Inspect 按布局拆分ID
+func (l Layout) Inspect(id uint64) IDInfo {
+	prefix, timestamp, node, seq := l.Decompose(id)
+	t := l.Time(timestamp)
+	return IDInfo{
+		ID:        id,
+		Prefix:    prefix,
+		Timestamp: timestamp,
+		Node:      node,
+		Seq:       seq,
+		UTC:       t.UTC(),
+		Local:     t.Local(),
+		Formatted: l.Format(id),
+		Layout:    l,
+	}
+}
@@ -0,0 +1,290 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ErrLeaseLost 节点租约已丢失，发号器停止发号
+var ErrLeaseLost = errors.New("节点租约已丢失")
+
+//ErrNoFreeNode 没有空闲的节点号
+var ErrNoFreeNode = errors.New("没有空闲的节点号")
+
+//LeaseStore 节点号租约存储，同一个prefix下每个节点号同时只能被一个持有者占用
+type LeaseStore interface {
+	//Acquire 占用一个[0,maxNode]之间的空闲节点号，租约ttl后过期
+	Acquire(prefix, maxNode uint, ttl time.Duration) (uint, error)
+	//Renew 续约，租约已经不属于自己时返回ErrLeaseLost
+	Renew(prefix, node uint, ttl time.Duration) error
+	//Release 释放租约
+	Release(prefix, node uint) error
+}
+
+//DefaultLeaseTTL 自动分配节点号的默认租约时长，每ttl/3续约一次
+var DefaultLeaseTTL = 30 * time.Second
+
+//NewIDGeneratorAuto 从store中占用一个空闲的节点号创建发号器，后台定期续约，
+//租约丢失后NextIDE返回ErrLeaseLost，NextID panic。不再使用时调用Close释放节点号
+func NewIDGeneratorAuto(prefix uint, store LeaseStore) (*IDGenerator, error) {
+	return NewIDGeneratorAutoWithLayout(DefaultLayout, prefix, store)
+}
+
+//NewIDGeneratorAutoWithLayout 使用指定的布局自动分配节点号创建发号器
+func NewIDGeneratorAutoWithLayout(layout Layout, prefix uint, store LeaseStore) (*IDGenerator, error) {
+	return NewIDGeneratorWithOptions(IDGeneratorOptions{Layout: layout, Prefix: prefix, Lease: store})
+}
+
+//acquireLease 占用节点号并开始续约
+func acquireLease(store LeaseStore, prefix, maxNode uint) (*nodeLease, error) {
+	ttl := DefaultLeaseTTL
+	start := time.Now()
+	node, err := store.Acquire(prefix, maxNode, ttl)
+	if err != nil {
+		return nil, err
+	}
+	lease := &nodeLease{store: store, prefix: prefix, node: node, ttl: ttl, stop: make(chan struct{})}
+	lease.extend(start)
+	go lease.heartbeat()
+	return lease, nil
+}
+
+type nodeLease struct {
+	//deadline 租约确定有效的截止时间(UnixNano)
+	deadline int64
+	lost     uint32
+	store    LeaseStore
+	prefix   uint
+	node     uint
+	ttl      time.Duration
+	stop     chan struct{}
+	once     sync.Once
+}
+
+//extend 续约成功后更新截止时间。从发起续约的时间开始计算，并留出ttl/10的余量，
+//保证截止时间之前存储中的租约一定还没有过期
+func (l *nodeLease) extend(start time.Time) {
+	atomic.StoreInt64(&l.deadline, start.Add(l.ttl-l.ttl/10).UnixNano())
+}
+
+//valid 租约在now时是否有效，超过截止时间后即使还在续约也认为无效
+func (l *nodeLease) valid(now time.Time) bool {
+	return atomic.LoadUint32(&l.lost) == 0 && now.UnixNano() < atomic.LoadInt64(&l.deadline)
+}
+
+//heartbeat 定期续约，返回ErrLeaseLost时认为租约丢失，其他错误继续重试
+func (l *nodeLease) heartbeat() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		err := l.store.Renew(l.prefix, l.node, l.ttl)
+		if err == nil {
+			l.extend(start)
+			continue
+		}
+		log.Println("idgen renew lease", l.prefix, l.node, err)
+		if err == ErrLeaseLost {
+			atomic.StoreUint32(&l.lost, 1)
+			return
+		}
+	}
+}
+
+func (l *nodeLease) close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		atomic.StoreUint32(&l.lost, 1)
+		err = l.store.Release(l.prefix, l.node)
+	})
+	return err
+}
+
+//FileLeaseStore 基于本地目录的租约存储，适合单机部署，每个节点号对应一个文件
+type FileLeaseStore struct {
+	dir   string
+	owner string
+	lock  sync.Mutex
+}
+
+//NewFileLeaseStore ....
+func NewFileLeaseStore(dir string) (*FileLeaseStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileLeaseStore{dir: dir, owner: UUID()}, nil
+}
+
+func (s *FileLeaseStore) path(prefix, node uint) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d-%d.lease", prefix, node))
+}
+
+//readLease 文件内容为"持有者 过期时间(UnixNano)"
+func (s *FileLeaseStore) readLease(path string) (string, time.Time, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return "", time.Time{}, fmt.Errorf("%s: invalid lease", path)
+	}
+	exp, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: invalid lease", path)
+	}
+	return fields[0], time.Unix(0, exp), nil
+}
+
+func (s *FileLeaseStore) leaseData(ttl time.Duration) []byte {
+	return []byte(fmt.Sprintf("%s %d", s.owner, time.Now().Add(ttl).UnixNano()))
+}
+
+func (s *FileLeaseStore) writeLease(path string, ttl time.Duration) error {
+	tmp := path + "." + s.owner
+	if err := ioutil.WriteFile(tmp, s.leaseData(ttl), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//expired 租约是否过期，内容不完整（可能正在被其他进程写入）时按文件修改时间判断
+func (s *FileLeaseStore) expired(path string, ttl time.Duration) bool {
+	_, exp, err := s.readLease(path)
+	if err == nil {
+		return !time.Now().Before(exp)
+	}
+	fi, err := os.Stat(path)
+	return err == nil && time.Since(fi.ModTime()) > ttl
+}
+
+//errNodeLocked 其他进程正在操作这个节点号的租约
+var errNodeLocked = errors.New("节点号租约正在被其他进程操作")
+
+//lockNode 用O_EXCL创建的锁文件保护接管、续约和释放，保证跨进程互斥。
+//持有者崩溃留下的锁文件超过ttl后视为失效
+func (s *FileLeaseStore) lockNode(path string, ttl time.Duration) (func(), error) {
+	lock := path + ".lock"
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		fi, err := os.Stat(lock)
+		if err == nil && time.Since(fi.ModTime()) <= ttl {
+			return nil, errNodeLocked
+		}
+		os.Remove(lock)
+	}
+	return nil, errNodeLocked
+}
+
+//takeover 在锁内再次确认租约已过期后写入自己的租约
+func (s *FileLeaseStore) takeover(path string, ttl time.Duration) (bool, error) {
+	unlock, err := s.lockNode(path, ttl)
+	if err == errNodeLocked {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if !s.expired(path, ttl) {
+		return false, nil
+	}
+	if err := s.writeLease(path, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//Acquire ....
+func (s *FileLeaseStore) Acquire(prefix, maxNode uint, ttl time.Duration) (uint, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for node := uint(0); node <= maxNode; node++ {
+		path := s.path(prefix, node)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(s.leaseData(ttl))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return 0, err
+			}
+			return node, nil
+		}
+		if !os.IsExist(err) {
+			return 0, err
+		}
+		if !s.expired(path, ttl) {
+			continue
+		}
+		//租约已过期，接管
+		ok, err := s.takeover(path, ttl)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return node, nil
+		}
+	}
+	return 0, ErrNoFreeNode
+}
+
+//Renew ....
+func (s *FileLeaseStore) Renew(prefix, node uint, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	path := s.path(prefix, node)
+	unlock, err := s.lockNode(path, ttl)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	owner, _, err := s.readLease(path)
+	if os.IsNotExist(err) || (err == nil && owner != s.owner) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	return s.writeLease(path, ttl)
+}
+
+//Release ....
+func (s *FileLeaseStore) Release(prefix, node uint) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	path := s.path(prefix, node)
+	unlock, err := s.lockNode(path, DefaultLeaseTTL)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	owner, _, err := s.readLease(path)
+	if err != nil || owner != s.owner {
+		return nil
+	}
+	return os.Remove(path)
+}
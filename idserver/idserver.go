@@ -0,0 +1,203 @@
+//Package idserver 把IDGenerator包装成HTTP服务，供非Go服务使用同一个ID空间
+//
+//	GET /id                      {"id":"3517066276804493313"}
+//	GET /ids?n=10                {"ids":["...", ...]}
+//	GET /id/format?id=...        ID的各个组成部分及各种编码
+//	GET /id/parse?s=...&enc=...  把formatted、base62、base32或sortable编码的ID还原
+//	GET /healthz                 {"status":"ok","state":"..."}
+//
+//ID以字符串返回，避免JavaScript等语言丢失精度
+package idserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gqf2008/misc"
+)
+
+//Config 服务配置，通过misc.Fill从环境变量读取
+type Config struct {
+	Addr     string `default:":8080" desc:"监听地址"`
+	Layout   string `default:"default" oneof:"default snowflake" desc:"ID布局"`
+	Prefix   uint   `default:"0" desc:"ID前缀"`
+	Node     uint   `default:"0" desc:"节点号，同一个ID空间内每个实例必须不同"`
+	MaxBatch int    `default:"1000" min:"1" desc:"/ids一次最多获取的ID数量"`
+	State    string `desc:"保存已使用时间戳的文件路径，设置后启动时不再等待并检查时钟回拨"`
+}
+
+//LoadConfig 读取prefix下的配置
+func LoadConfig(prefix string) (Config, error) {
+	c := Config{}
+	err := misc.Fill(prefix, &c)
+	return c, err
+}
+
+//Server 实现http.Handler
+type Server struct {
+	gen      *misc.IDGenerator
+	layout   misc.Layout
+	maxBatch int
+	mux      *http.ServeMux
+}
+
+//New 使用已创建的发号器创建服务
+func New(gen *misc.IDGenerator, maxBatch int) *Server {
+	if maxBatch <= 0 {
+		maxBatch = 1000
+	}
+	s := &Server{gen: gen, layout: gen.Layout(), maxBatch: maxBatch, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/id", s.handleID)
+	s.mux.HandleFunc("/ids", s.handleIDs)
+	s.mux.HandleFunc("/id/format", s.handleFormat)
+	s.mux.HandleFunc("/id/parse", s.handleParse)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	return s
+}
+
+//NewFromConfig 按配置创建发号器和服务，不再使用时调用Close
+func NewFromConfig(c Config) (*Server, error) {
+	opts := misc.IDGeneratorOptions{Prefix: c.Prefix, Node: c.Node}
+	switch c.Layout {
+	case "", "default":
+		opts.Layout = misc.DefaultLayout
+	case "snowflake":
+		opts.Layout = misc.SnowflakeLayout
+	default:
+		return nil, fmt.Errorf("unknown layout %s", c.Layout)
+	}
+	if c.State != "" {
+		opts.State = misc.NewFileStateStore(c.State)
+	}
+	gen, err := misc.NewIDGeneratorWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return New(gen, c.MaxBatch), nil
+}
+
+//Close 关闭发号器
+func (s *Server) Close() error {
+	return s.gen.Close()
+}
+
+//ServeHTTP ....
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+type idResponse struct {
+	ID uint64 `json:"id,string"`
+}
+
+type idsResponse struct {
+	IDs []string `json:"ids"`
+}
+
+type infoResponse struct {
+	misc.IDInfo
+	//ID 覆盖IDInfo.ID，以字符串输出
+	ID       uint64 `json:"id,string"`
+	Base62   string `json:"base62"`
+	Base32   string `json:"base32"`
+	Sortable string `json:"sortable"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	State  string `json:"state"`
+}
+
+func (s *Server) handleID(w http.ResponseWriter, r *http.Request) {
+	id, err := s.gen.NextIDE()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, idResponse{id})
+}
+
+func (s *Server) handleIDs(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n <= 0 || n > s.maxBatch {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("n must be in [1-%d]", s.maxBatch))
+		return
+	}
+	ids, err := s.gen.NextIDsE(n)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	resp := idsResponse{IDs: make([]string, len(ids))}
+	for i, id := range ids {
+		resp.IDs[i] = strconv.FormatUint(id, 10)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleFormat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid id: %s", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.inspect(id))
+}
+
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id, err := s.parse(q.Get("enc"), q.Get("s"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.inspect(id))
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{"ok", s.gen.State()})
+}
+
+func (s *Server) inspect(id uint64) infoResponse {
+	return infoResponse{
+		IDInfo:   s.layout.Inspect(id),
+		ID:       id,
+		Base62:   misc.FormatIDBase62(id),
+		Base32:   misc.FormatIDBase32(id),
+		Sortable: misc.FormatIDSortable(id),
+	}
+}
+
+//parse enc为空时按formatted解析
+func (s *Server) parse(enc, v string) (uint64, error) {
+	switch enc {
+	case "", "formatted":
+		return s.layout.Parse(v)
+	case "number":
+		return strconv.ParseUint(v, 10, 64)
+	case "base62":
+		return misc.ParseIDBase62(v)
+	case "base32":
+		return misc.ParseIDBase32(v)
+	case "sortable":
+		return misc.ParseIDSortable(v)
+	}
+	return 0, fmt.Errorf("unknown encoding %s", enc)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,120 @@
+package misc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//StateStore 持久化发号器已经使用（预留）到的时间
+type StateStore interface {
+	//Load 返回上次保存的时间，从未保存过时返回零值
+	Load() (time.Time, error)
+	Save(t time.Time) error
+}
+
+//restore 读取上次保存的时间，时钟晚于该时间时直接启动，否则最多等待MaxStartupWait
+func (p *IDGenerator) restore(opts IDGeneratorOptions) error {
+	p.store = opts.State
+	ahead := opts.StateAhead
+	if ahead <= 0 {
+		ahead = time.Second
+	}
+	p.ahead = int64(ahead / p.layout.Unit)
+	if p.ahead < 1 {
+		p.ahead = 1
+	}
+	//保存的是预留到的时间，正常重启时时钟也可能落后最多StateAhead，
+	//所以等待时长不能小于StateAhead，否则崩溃后重启会被误判为时钟回拨
+	maxWait := opts.MaxStartupWait
+	if maxWait <= 0 {
+		maxWait = 2 * ahead
+	}
+	if maxWait < ahead {
+		maxWait = ahead
+	}
+	last, err := p.store.Load()
+	if err != nil {
+		return err
+	}
+	if !last.IsZero() {
+		saved := p.layout.tick(last)
+		current := p.layout.tick(time.Now())
+		if current <= saved {
+			behind := time.Duration(saved-current+1) * p.layout.Unit
+			if behind > maxWait {
+				return fmt.Errorf("%w，时钟落后于上次保存的时间%s", ErrClockRollback, behind)
+			}
+			time.Sleep(time.Until(time.Unix(0, (saved+1)*int64(p.layout.Unit))))
+		}
+	}
+	return p.extend(p.layout.tick(time.Now()))
+}
+
+//extend 把预留的时间延长到tick之后ahead个时间单位并保存
+func (p *IDGenerator) extend(tick int64) error {
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+	if tick <= atomic.LoadInt64(&p.reserved) {
+		return nil
+	}
+	reserved := tick + p.ahead
+	if err := p.store.Save(time.Unix(0, reserved*int64(p.layout.Unit))); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&p.reserved, reserved)
+	return nil
+}
+
+//save 保存最后实际使用的时间戳，之后重启不需要等待预留的时间
+func (p *IDGenerator) save() error {
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+	last, _ := p.unpack(atomic.LoadUint64(&p.state))
+	tick := last + p.epoch
+	if err := p.store.Save(time.Unix(0, tick*int64(p.layout.Unit))); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&p.reserved, tick)
+	return nil
+}
+
+//FileStateStore 把时间以毫秒时间戳保存在本地文件中
+type FileStateStore struct {
+	path string
+}
+
+//NewFileStateStore ....
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+//Load ....
+func (s *FileStateStore) Load() (time.Time, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s:%s", s.path, err)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+//Save ....
+func (s *FileStateStore) Save(t time.Time) error {
+	tmp := s.path + ".tmp"
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(ms, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
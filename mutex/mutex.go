@@ -23,7 +23,7 @@ type config struct {
 
 //Redis ....
 type Redis struct {
-	Addr string `default:"redis://127.0.0.1:6379/0?poolsize=200&retries=3&pool_timeout=30"`
+	Addr string `default:"redis://127.0.0.1:6379/0?poolsize=200&retries=3&pool_timeout=30" desc:"redis地址，支持poolsize参数"`
 }
 
 //NewMutex ....
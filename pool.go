@@ -1,7 +1,11 @@
 package misc
 
 import (
+	"context"
+	"errors"
+	"log"
 	"runtime"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,6 +34,9 @@ var coarseTime atomic.Value
 //WorkerFunc ....
 type WorkerFunc = func()
 
+//ErrorFunc 返回错误的任务，通过WorkerPool.ErrorTask包装后提交，错误交给ErrorHandler处理
+type ErrorFunc = func() error
+
 // WorkerPool serves incoming connections via a pool of workers
 // in FILO order, i.e. the most recently stopped worker will serve the next
 // incoming connection.
@@ -42,19 +49,36 @@ type WorkerPool struct {
 
 	MaxWorkersCount int
 
+	//LogAllErrors 记录所有任务返回的错误和panic，即使设置了ErrorHandler、PanicHandler
 	LogAllErrors bool
 
+	//PanicHandler 任务panic时调用，worker继续运行。为空时记录日志
+	PanicHandler func(recovered interface{}, stack []byte)
+
+	//ErrorHandler ErrorFunc任务返回错误时调用。为空时只在LogAllErrors为true时记录日志
+	ErrorHandler func(err error)
+
 	MaxIdleWorkerDuration time.Duration
 
+	//MaxPendingTasks 没有空闲worker时最多排队的任务数，默认0不排队
+	MaxPendingTasks int
+
 	lock         sync.Mutex
 	workersCount int
 	mustStop     bool
+	//tasks 已经接受但还没有执行完的任务数，包括排队中的任务
+	tasks   int
+	drained chan struct{}
 
-	ready []*workerChan
+	ready   []*workerChan
+	pending []WorkerFunc
+	waiters []*submitWaiter
 
 	stopCh chan struct{}
 
 	workerChanPool sync.Pool
+
+	stats poolStats
 }
 
 type workerChan struct {
@@ -62,6 +86,19 @@ type workerChan struct {
 	ch          chan WorkerFunc
 }
 
+//submitWaiter Submit中等待空闲worker或排队位置的任务
+type submitWaiter struct {
+	f  WorkerFunc
+	ch chan error
+}
+
+var (
+	//ErrPoolFull 没有空闲的worker并且排队已满
+	ErrPoolFull = errors.New("没有空闲的worker")
+	//ErrPoolStopped WorkerPool已经停止
+	ErrPoolStopped = errors.New("WorkerPool已经停止")
+)
+
 //Start ....
 func (wp *WorkerPool) Start() {
 	if wp.stopCh != nil {
@@ -69,6 +106,10 @@ func (wp *WorkerPool) Start() {
 	}
 	wp.stopCh = make(chan struct{})
 	stopCh := wp.stopCh
+	wp.lock.Lock()
+	wp.mustStop = false
+	wp.drained = nil
+	wp.lock.Unlock()
 	go func() {
 		var scratch []*workerChan
 		for {
@@ -90,7 +131,10 @@ func (wp *WorkerPool) Stop() {
 	}
 	close(wp.stopCh)
 	wp.stopCh = nil
+	wp.stopWorkers()
+}
 
+func (wp *WorkerPool) stopWorkers() {
 	// Stop all the workers waiting for incoming connections.
 	// Do not wait for busy workers - they will stop after
 	// serving the connection and noticing wp.mustStop = true.
@@ -102,7 +146,51 @@ func (wp *WorkerPool) Stop() {
 	}
 	wp.ready = ready[:0]
 	wp.mustStop = true
+	// Queued tasks are still served by the busy workers,
+	// blocked submitters are rejected.
+	for _, w := range wp.waiters {
+		w.ch <- ErrPoolStopped
+	}
+	wp.waiters = nil
+	wp.lock.Unlock()
+}
+
+//StopAndWait 停止接受新任务，等待正在执行和排队中的任务执行完。
+//ctx结束时返回还没有执行完的任务数和ctx.Err()。没有Start过或者已经停止时不会panic，只等待任务执行完
+func (wp *WorkerPool) StopAndWait(ctx context.Context) (int, error) {
+	wp.lock.Lock()
+	stopped := wp.mustStop
+	wp.lock.Unlock()
+	if !stopped {
+		if wp.stopCh != nil {
+			wp.Stop()
+		} else {
+			wp.stopWorkers()
+		}
+	}
+	wp.lock.Lock()
+	if wp.tasks == 0 {
+		wp.lock.Unlock()
+		return 0, nil
+	}
+	if wp.drained == nil {
+		wp.drained = make(chan struct{})
+	}
+	drained := wp.drained
+	wp.lock.Unlock()
+
+	select {
+	case <-drained:
+		return 0, nil
+	case <-ctx.Done():
+	}
+	wp.lock.Lock()
+	n := wp.tasks
 	wp.lock.Unlock()
+	if n == 0 {
+		return 0, nil
+	}
+	return n, ctx.Err()
 }
 
 func (wp *WorkerPool) getMaxIdleWorkerDuration() time.Duration {
@@ -147,14 +235,76 @@ func (wp *WorkerPool) clean(scratch *[]*workerChan) {
 	}
 }
 
-//Serve ....
+//Serve 没有空闲的worker并且排队已满时立即返回false
 func (wp *WorkerPool) Serve(f WorkerFunc) bool {
-	ch := wp.getCh()
-	if ch == nil {
-		return false
+	return wp.TrySubmit(f) == nil
+}
+
+//TrySubmit 提交任务，没有空闲的worker并且排队已满时立即返回ErrPoolFull
+func (wp *WorkerPool) TrySubmit(f WorkerFunc) error {
+	return wp.submit(nil, f)
+}
+
+//Submit 提交任务，没有空闲的worker并且排队已满时等待，直到有worker空闲、ctx结束或者WorkerPool停止
+func (wp *WorkerPool) Submit(ctx context.Context, f WorkerFunc) error {
+	return wp.submit(ctx, f)
+}
+
+//submit ctx为nil时不等待
+func (wp *WorkerPool) submit(ctx context.Context, f WorkerFunc) (err error) {
+	defer func() {
+		if err != nil {
+			wp.stats.reject()
+		}
+	}()
+	if f == nil {
+		return errors.New("task is nil")
+	}
+	wp.lock.Lock()
+	if wp.mustStop {
+		wp.lock.Unlock()
+		return ErrPoolStopped
+	}
+	ch, create := wp.takeReady()
+	if ch != nil || create {
+		wp.tasks++
+		wp.lock.Unlock()
+		if ch == nil {
+			ch = wp.startWorker()
+		}
+		ch.ch <- f
+		return nil
 	}
-	ch.ch <- f
-	return true
+	if len(wp.pending) < wp.MaxPendingTasks {
+		wp.pending = append(wp.pending, f)
+		wp.tasks++
+		wp.lock.Unlock()
+		return nil
+	}
+	if ctx == nil {
+		wp.lock.Unlock()
+		return ErrPoolFull
+	}
+	w := &submitWaiter{f: f, ch: make(chan error, 1)}
+	wp.waiters = append(wp.waiters, w)
+	wp.lock.Unlock()
+
+	select {
+	case err = <-w.ch:
+		return err
+	case <-ctx.Done():
+	}
+	wp.lock.Lock()
+	for i, x := range wp.waiters {
+		if x == w {
+			wp.waiters = append(wp.waiters[:i], wp.waiters[i+1:]...)
+			wp.lock.Unlock()
+			return ctx.Err()
+		}
+	}
+	wp.lock.Unlock()
+	// Already handed to a worker or rejected by Stop.
+	return <-w.ch
 }
 
 var workerChanCap = func() int {
@@ -171,65 +321,95 @@ var workerChanCap = func() int {
 	return 1
 }()
 
-func (wp *WorkerPool) getCh() *workerChan {
-	var ch *workerChan
-	createWorker := false
-
-	wp.lock.Lock()
+//takeReady 取出最近使用过的空闲worker，没有空闲的worker时返回是否还能创建新的worker，调用时必须持有锁
+func (wp *WorkerPool) takeReady() (*workerChan, bool) {
 	ready := wp.ready
 	n := len(ready) - 1
 	if n < 0 {
 		if wp.workersCount < wp.MaxWorkersCount {
-			createWorker = true
 			wp.workersCount++
+			return nil, true
 		}
-	} else {
-		ch = ready[n]
-		ready[n] = nil
-		wp.ready = ready[:n]
+		return nil, false
 	}
-	wp.lock.Unlock()
+	ch := ready[n]
+	ready[n] = nil
+	wp.ready = ready[:n]
+	return ch, false
+}
 
-	if ch == nil {
-		if !createWorker {
-			return nil
-		}
-		vch := wp.workerChanPool.Get()
-		if vch == nil {
-			vch = &workerChan{
-				ch: make(chan WorkerFunc, workerChanCap),
-			}
+func (wp *WorkerPool) startWorker() *workerChan {
+	vch := wp.workerChanPool.Get()
+	if vch == nil {
+		vch = &workerChan{
+			ch: make(chan WorkerFunc, workerChanCap),
 		}
-		ch = vch.(*workerChan)
-		go func() {
-			wp.workerFunc(ch)
-			wp.workerChanPool.Put(vch)
-		}()
 	}
+	ch := vch.(*workerChan)
+	go func() {
+		wp.workerFunc(ch)
+		wp.workerChanPool.Put(vch)
+	}()
 	return ch
 }
 
-func (wp *WorkerPool) release(ch *workerChan) bool {
+//dequeue 取出排队的任务，并把第一个等待的Submit移入队列，调用时必须持有锁
+func (wp *WorkerPool) dequeue() WorkerFunc {
+	var f WorkerFunc
+	if len(wp.pending) > 0 {
+		f = wp.pending[0]
+		wp.pending[0] = nil
+		wp.pending = wp.pending[1:]
+	}
+	if len(wp.waiters) > 0 {
+		w := wp.waiters[0]
+		wp.waiters[0] = nil
+		wp.waiters = wp.waiters[1:]
+		w.ch <- nil
+		wp.tasks++
+		if f == nil {
+			f = w.f
+		} else {
+			wp.pending = append(wp.pending, w.f)
+		}
+	}
+	return f
+}
+
+//release 有排队的任务时返回该任务继续执行，否则把worker放回空闲列表，WorkerPool已经停止时返回false
+func (wp *WorkerPool) release(ch *workerChan) (WorkerFunc, bool) {
 	ch.lastUseTime = CoarseTimeNow()
 	wp.lock.Lock()
+	wp.tasks--
+	if wp.tasks == 0 && wp.drained != nil {
+		close(wp.drained)
+		wp.drained = nil
+	}
+	if f := wp.dequeue(); f != nil {
+		wp.lock.Unlock()
+		return f, true
+	}
 	if wp.mustStop {
 		wp.lock.Unlock()
-		return false
+		return nil, false
 	}
 	wp.ready = append(wp.ready, ch)
 	wp.lock.Unlock()
-	return true
+	return nil, true
 }
 
 func (wp *WorkerPool) workerFunc(ch *workerChan) {
 	var f WorkerFunc
+	ok := true
 	for f = range ch.ch {
 		if f == nil {
 			break
 		}
-		f()
-		f = nil
-		if !wp.release(ch) {
+		for f != nil {
+			wp.run(f)
+			f, ok = wp.release(ch)
+		}
+		if !ok {
 			break
 		}
 	}
@@ -238,3 +418,41 @@ func (wp *WorkerPool) workerFunc(ch *workerChan) {
 	wp.workersCount--
 	wp.lock.Unlock()
 }
+
+//run 执行任务，恢复任务中的panic
+func (wp *WorkerPool) run(f WorkerFunc) {
+	start := time.Now()
+	defer func() {
+		r := recover()
+		wp.stats.done(time.Since(start), r != nil)
+		if r != nil {
+			stack := debug.Stack()
+			if wp.PanicHandler == nil || wp.LogAllErrors {
+				log.Printf("workerpool: task panic: %v\n%s", r, stack)
+			}
+			if wp.PanicHandler != nil {
+				wp.PanicHandler(r, stack)
+			}
+		}
+	}()
+	f()
+}
+
+//ErrorTask 把ErrorFunc包装成WorkerFunc，返回的错误交给ErrorHandler处理
+func (wp *WorkerPool) ErrorTask(f ErrorFunc) WorkerFunc {
+	return func() {
+		if err := f(); err != nil {
+			wp.handleError(err)
+		}
+	}
+}
+
+func (wp *WorkerPool) handleError(err error) {
+	wp.stats.fail()
+	if wp.LogAllErrors {
+		log.Println("workerpool: task error:", err)
+	}
+	if wp.ErrorHandler != nil {
+		wp.ErrorHandler(err)
+	}
+}
@@ -0,0 +1,97 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//Future 提交到WorkerPool的函数的执行结果
+type Future struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+//PanicError 函数panic时Future返回的错误
+type PanicError struct {
+	Recovered interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panic: %v", e.Recovered)
+}
+
+//Go 在WorkerPool中执行f并返回Future，没有空闲的worker并且排队已满时等待。
+//WorkerPool已经停止时Future返回ErrPoolStopped；f panic时Future返回PanicError，panic同时交给PanicHandler处理
+func (wp *WorkerPool) Go(f func() (interface{}, error)) *Future {
+	fut := &Future{done: make(chan struct{})}
+	err := wp.Submit(context.Background(), func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fut.complete(nil, &PanicError{r})
+				panic(r)
+			}
+		}()
+		fut.complete(f())
+	})
+	if err != nil {
+		fut.complete(nil, err)
+	}
+	return fut
+}
+
+func (f *Future) complete(val interface{}, err error) {
+	f.val, f.err = val, err
+	close(f.done)
+}
+
+//Done 执行完成后关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+//Get 等待执行完成并返回结果，ctx先结束时返回ctx.Err()
+func (f *Future) Get(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//WaitAll 等待所有Future执行完成，返回按顺序第一个出错的Future的错误，ctx先结束时返回ctx.Err()
+func WaitAll(ctx context.Context, futures ...*Future) error {
+	for _, f := range futures {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, f := range futures {
+		if f.err != nil {
+			return f.err
+		}
+	}
+	return nil
+}
+
+//WaitAny 等待任意一个Future执行完成，返回它的下标，ctx先结束时返回-1和ctx.Err()
+func WaitAny(ctx context.Context, futures ...*Future) (int, error) {
+	if len(futures) == 0 {
+		return -1, errors.New("no futures")
+	}
+	cases := make([]reflect.SelectCase, 0, len(futures)+1)
+	for _, f := range futures {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	i, _, _ := reflect.Select(cases)
+	if i == len(futures) {
+		return -1, ctx.Err()
+	}
+	return i, nil
+}
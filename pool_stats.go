@@ -0,0 +1,101 @@
+package misc
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+//durationSamples 计算耗时分位数时保留的最近任务数
+const durationSamples = 1024
+
+//PoolStats WorkerPool的运行统计，耗时分位数按最近1024个任务计算
+type PoolStats struct {
+	Workers   int           `json:"workers"`
+	Idle      int           `json:"idle"`
+	Busy      int           `json:"busy"`
+	Pending   int           `json:"pending"`
+	Waiting   int           `json:"waiting"`
+	Rejected  uint64        `json:"rejected"`
+	Completed uint64        `json:"completed"`
+	Panics    uint64        `json:"panics"`
+	Errors    uint64        `json:"errors"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+type poolStats struct {
+	lock      sync.Mutex
+	rejected  uint64
+	completed uint64
+	panics    uint64
+	errors    uint64
+	durations [durationSamples]time.Duration
+	n         int
+}
+
+func (s *poolStats) done(d time.Duration, panicked bool) {
+	s.lock.Lock()
+	s.durations[s.completed%durationSamples] = d
+	s.completed++
+	if s.n < durationSamples {
+		s.n++
+	}
+	if panicked {
+		s.panics++
+	}
+	s.lock.Unlock()
+}
+
+func (s *poolStats) reject() {
+	s.lock.Lock()
+	s.rejected++
+	s.lock.Unlock()
+}
+
+func (s *poolStats) fail() {
+	s.lock.Lock()
+	s.errors++
+	s.lock.Unlock()
+}
+
+//Stats 获取当前的运行统计
+func (wp *WorkerPool) Stats() PoolStats {
+	var st PoolStats
+	wp.lock.Lock()
+	st.Workers = wp.workersCount
+	st.Idle = len(wp.ready)
+	st.Pending = len(wp.pending)
+	st.Waiting = len(wp.waiters)
+	st.Busy = wp.tasks - st.Pending
+	wp.lock.Unlock()
+
+	s := &wp.stats
+	s.lock.Lock()
+	st.Rejected = s.rejected
+	st.Completed = s.completed
+	st.Panics = s.panics
+	st.Errors = s.errors
+	durations := make([]time.Duration, s.n)
+	copy(durations, s.durations[:s.n])
+	s.lock.Unlock()
+
+	if n := len(durations); n > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		st.P50 = durations[n*50/100]
+		st.P90 = durations[n*90/100]
+		st.P99 = durations[n*99/100]
+		st.Max = durations[n-1]
+	}
+	return st
+}
+
+//Publish 通过expvar以name发布Stats，name重复时panic
+func (wp *WorkerPool) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return wp.Stats()
+	}))
+}
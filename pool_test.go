@@ -0,0 +1,236 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//fill 占满所有worker和排队位置，关闭返回的channel后这些任务结束
+func fill(t *testing.T, wp *WorkerPool) chan struct{} {
+	block := make(chan struct{})
+	for i := 0; i < wp.MaxWorkersCount+wp.MaxPendingTasks; i++ {
+		if err := wp.TrySubmit(func() { <-block }); err != nil {
+			t.Fatalf("TrySubmit %d: %v", i, err)
+		}
+	}
+	return block
+}
+
+func TestTrySubmitFull(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 2, MaxPendingTasks: 1}
+	wp.Start()
+	defer wp.Stop()
+	block := fill(t, wp)
+	defer close(block)
+	if err := wp.TrySubmit(func() {}); err != ErrPoolFull {
+		t.Fatalf("TrySubmit on full pool = %v, want ErrPoolFull", err)
+	}
+	if wp.Serve(func() {}) {
+		t.Fatal("Serve on full pool should return false")
+	}
+}
+
+func TestSubmitContextTimeout(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 1}
+	wp.Start()
+	defer wp.Stop()
+	block := fill(t, wp)
+	var ran int32
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := wp.Submit(ctx, func() { atomic.StoreInt32(&ran, 1) })
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Submit = %v, want context.DeadlineExceeded", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("Submit returned before ctx expired")
+	}
+	close(block)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("task of timed out Submit should not run")
+	}
+}
+
+func TestSubmitBlocksUntilWorkerFree(t *testing.T) {
+	for _, pending := range []int{0, 2} {
+		wp := &WorkerPool{MaxWorkersCount: 2, MaxPendingTasks: pending}
+		wp.Start()
+		block := fill(t, wp)
+		done := make(chan struct{})
+		res := make(chan error, 1)
+		go func() {
+			res <- wp.Submit(context.Background(), func() { close(done) })
+		}()
+		select {
+		case err := <-res:
+			t.Fatalf("pending=%d: Submit returned %v while pool is full", pending, err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(block)
+		if err := <-res; err != nil {
+			t.Fatalf("pending=%d: Submit = %v", pending, err)
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("pending=%d: submitted task did not run", pending)
+		}
+		wp.Stop()
+	}
+}
+
+func TestSubmitStopped(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 1}
+	wp.Start()
+	block := fill(t, wp)
+	defer close(block)
+	res := make(chan error, 1)
+	go func() {
+		res <- wp.Submit(context.Background(), func() {})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	wp.Stop()
+	if err := <-res; err != ErrPoolStopped {
+		t.Fatalf("blocked Submit after Stop = %v, want ErrPoolStopped", err)
+	}
+	if err := wp.TrySubmit(func() {}); err != ErrPoolStopped {
+		t.Fatalf("TrySubmit after Stop = %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestSubmitConcurrent(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 4, MaxPendingTasks: 4}
+	wp.Start()
+	defer wp.Stop()
+	const n = 10000
+	var count int64
+	done := make(chan struct{})
+	for g := 0; g < 8; g++ {
+		go func() {
+			for i := 0; i < n/8; i++ {
+				if err := wp.Submit(context.Background(), func() {
+					if atomic.AddInt64(&count, 1) == n {
+						close(done)
+					}
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("only %d of %d tasks ran", atomic.LoadInt64(&count), n)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	recovered := make(chan interface{}, 1)
+	errs := make(chan error, 1)
+	wp := &WorkerPool{
+		MaxWorkersCount: 1,
+		PanicHandler: func(r interface{}, stack []byte) {
+			if len(stack) == 0 {
+				t.Error("empty stack")
+			}
+			recovered <- r
+		},
+		ErrorHandler: func(err error) { errs <- err },
+	}
+	wp.Start()
+	defer wp.Stop()
+	if err := wp.Submit(context.Background(), func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-recovered; r != "boom" {
+		t.Fatalf("recovered %v, want boom", r)
+	}
+	want := errors.New("task failed")
+	if err := wp.Submit(context.Background(), wp.ErrorTask(func() error { return want })); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != want {
+		t.Fatalf("ErrorHandler got %v, want %v", err, want)
+	}
+	// 同一个worker在panic之后继续执行任务
+	done := make(chan struct{})
+	if err := wp.Submit(context.Background(), func() { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool stopped working after a panic")
+	}
+	if st := wp.Stats(); st.Panics != 1 || st.Errors != 1 {
+		t.Fatalf("Stats panics=%d errors=%d, want 1 and 1", st.Panics, st.Errors)
+	}
+}
+
+func TestStopAndWait(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 2, MaxPendingTasks: 2}
+	wp.Start()
+	var done int32
+	for i := 0; i < 4; i++ {
+		if err := wp.TrySubmit(func() {
+			time.Sleep(30 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := wp.StopAndWait(context.Background())
+	if n != 0 || err != nil {
+		t.Fatalf("StopAndWait = %d, %v", n, err)
+	}
+	if d := atomic.LoadInt32(&done); d != 4 {
+		t.Fatalf("%d tasks finished, want 4", d)
+	}
+	if err := wp.TrySubmit(func() {}); err != ErrPoolStopped {
+		t.Fatalf("TrySubmit after StopAndWait = %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestStopAndWaitTimeout(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 2, MaxPendingTasks: 1}
+	wp.Start()
+	block := fill(t, wp)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	n, err := wp.StopAndWait(ctx)
+	if err != context.DeadlineExceeded || n != 3 {
+		t.Fatalf("StopAndWait = %d, %v, want 3, context.DeadlineExceeded", n, err)
+	}
+	close(block)
+	// 再次调用不会panic，并等待剩余的任务执行完
+	n, err = wp.StopAndWait(context.Background())
+	if n != 0 || err != nil {
+		t.Fatalf("second StopAndWait = %d, %v", n, err)
+	}
+}
+
+func TestStopAndWaitNotStarted(t *testing.T) {
+	wp := &WorkerPool{MaxWorkersCount: 1}
+	done := make(chan struct{})
+	if err := wp.TrySubmit(func() {
+		time.Sleep(20 * time.Millisecond)
+		close(done)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := wp.StopAndWait(context.Background()); n != 0 || err != nil {
+		t.Fatalf("StopAndWait = %d, %v", n, err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("StopAndWait returned before the task finished")
+	}
+}
@@ -1,6 +1,7 @@
 package rediss
 
 import (
+	"context"
 	"log"
 	"strconv"
 	"time"
@@ -61,6 +62,15 @@ func (t *DelayTask) Stop() {
 	}
 }
 
+//StopAndWait 停止取任务，等待已经取出的任务执行完，返回ctx结束时还没有执行完的任务数
+func (t *DelayTask) StopAndWait(ctx context.Context) (int, error) {
+	t.stop <- struct{}{}
+	if t.pool == nil {
+		return 0, nil
+	}
+	return t.pool.StopAndWait(ctx)
+}
+
 //Add ....
 func (t *DelayTask) Add(task interface{}, deadline time.Duration) error {
 	return cli.ZAdd(t.name, redis.Z{Member: task, Score: float64(deadline)}).Err()
@@ -97,17 +107,15 @@ func (t *DelayTask) loop() {
 			continue
 		}
 		for _, val := range ret.Val() {
-			for {
-				if t.pool.Serve(func() {
-					t.afterFunc(val.Member, time.Duration(val.Score))
-				}) {
-					if err := cli.ZRem(t.name, val.Member).Err(); err != nil {
-						log.Println("zrem delay_task", t.name, val.Member, err)
-					}
-					break
-				}
-				log.Println("delay_task not enough worker")
-				time.Sleep(time.Second)
+			member, score := val.Member, val.Score
+			if err := t.pool.Submit(context.Background(), func() {
+				t.afterFunc(member, time.Duration(score))
+			}); err != nil {
+				log.Println("delay_task", t.name, err)
+				return
+			}
+			if err := cli.ZRem(t.name, member).Err(); err != nil {
+				log.Println("zrem delay_task", t.name, member, err)
 			}
 		}
 		time.Sleep(time.Second)
@@ -0,0 +1,68 @@
+package rediss
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/gqf2008/misc"
+)
+
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+//NewLeaseStore 基于redis的节点号租约存储，key为name:prefix:node
+func NewLeaseStore(name string) *LeaseStore {
+	_init()
+	return &LeaseStore{name: name, owner: misc.UUID()}
+}
+
+//LeaseStore 实现misc.LeaseStore
+type LeaseStore struct {
+	name  string
+	owner string
+}
+
+func (s *LeaseStore) key(prefix, node uint) string {
+	return fmt.Sprintf("%s:%d:%d", s.name, prefix, node)
+}
+
+//Acquire ....
+func (s *LeaseStore) Acquire(prefix, maxNode uint, ttl time.Duration) (uint, error) {
+	for node := uint(0); node <= maxNode; node++ {
+		ok, err := cli.SetNX(s.key(prefix, node), s.owner, ttl).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return node, nil
+		}
+	}
+	return 0, misc.ErrNoFreeNode
+}
+
+//Renew ....
+func (s *LeaseStore) Renew(prefix, node uint, ttl time.Duration) error {
+	n, err := renewScript.Run(cli, []string{s.key(prefix, node)}, s.owner, int64(ttl/time.Millisecond)).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return misc.ErrLeaseLost
+	}
+	return nil
+}
+
+//Release ....
+func (s *LeaseStore) Release(prefix, node uint) error {
+	return releaseScript.Run(cli, []string{s.key(prefix, node)}, s.owner).Err()
+}
@@ -0,0 +1,41 @@
+package rediss
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//NewStateStore 基于redis的发号器状态存储，时间以毫秒时间戳保存在key中，
+//多个节点需要使用不同的key
+func NewStateStore(key string) *StateStore {
+	_init()
+	return &StateStore{key}
+}
+
+//StateStore 实现misc.StateStore
+type StateStore struct {
+	key string
+}
+
+//Load ....
+func (s *StateStore) Load() (time.Time, error) {
+	v, err := cli.Get(s.key).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+//Save ....
+func (s *StateStore) Save(t time.Time) error {
+	return cli.Set(s.key, t.UnixNano()/int64(time.Millisecond), 0).Err()
+}
@@ -19,7 +19,7 @@ type config struct {
 
 //Redis ....
 type Redis struct {
-	Addr string `default:"redis://127.0.0.1:6379/1?poolsize=200&retries=3&pool_timeout=30"`
+	Addr string `default:"redis://127.0.0.1:6379/1?poolsize=200&retries=3&pool_timeout=30" desc:"redis地址，支持poolsize参数"`
 }
 
 func _init() {
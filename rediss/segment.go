@@ -0,0 +1,21 @@
+package rediss
+
+//NewSegmentStore 基于redis INCRBY的号段存储，key为prefix加上业务key
+func NewSegmentStore(prefix string) *SegmentStore {
+	_init()
+	return &SegmentStore{prefix}
+}
+
+//SegmentStore 实现misc.SegmentStore
+type SegmentStore struct {
+	prefix string
+}
+
+//NextSegment ....
+func (s *SegmentStore) NextSegment(key string, size int64) (int64, error) {
+	end, err := cli.IncrBy(s.prefix+key, size).Result()
+	if err != nil {
+		return 0, err
+	}
+	return end - size + 1, nil
+}
@@ -0,0 +1,184 @@
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//SegmentStore 号段存储，为key预留size个连续的号码，返回第一个号码，
+//同一个key多次预留的号段严格递增且不重叠
+type SegmentStore interface {
+	NextSegment(key string, size int64) (int64, error)
+}
+
+//SegmentAllocator 号段模式发号器，从SegmentStore批量预留号段，同一个key下的号码稠密且严格递增。
+//当前号段消耗到一定比例时在后台预取下一个号段（双buffer）
+type SegmentAllocator struct {
+	key     string
+	store   SegmentStore
+	size    int64
+	preload float64
+
+	lock    sync.Mutex
+	next    int64
+	end     int64
+	buffer  *segment
+	loading chan struct{}
+	loadErr error
+}
+
+type segment struct {
+	start int64
+	end   int64
+}
+
+//NewSegmentAllocator 创建号段发号器并预留第一个号段，size为每次预留的数量
+func NewSegmentAllocator(key string, store SegmentStore, size int64) (*SegmentAllocator, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("号段大小必须大于0")
+	}
+	a := &SegmentAllocator{key: key, store: store, size: size, preload: 0.1}
+	seg, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	a.next, a.end = seg.start, seg.end
+	return a, nil
+}
+
+//WithPreload 当前号段消耗超过ratio时预取下一个号段，默认0.1
+func (a *SegmentAllocator) WithPreload(ratio float64) *SegmentAllocator {
+	if ratio < 0 || ratio > 1 {
+		panic(fmt.Errorf("预取比例必须在[0-1]之间"))
+	}
+	a.preload = ratio
+	return a
+}
+
+func (a *SegmentAllocator) load() (*segment, error) {
+	start, err := a.store.NextSegment(a.key, a.size)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{start: start, end: start + a.size}, nil
+}
+
+//NextID 获取下一个号码，出错时panic
+func (a *SegmentAllocator) NextID() uint64 {
+	id, err := a.NextIDE()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+//NextIDE 获取下一个号码
+func (a *SegmentAllocator) NextIDE() (uint64, error) {
+	a.lock.Lock()
+	for a.next >= a.end {
+		if a.buffer != nil {
+			a.next, a.end = a.buffer.start, a.buffer.end
+			a.buffer = nil
+			break
+		}
+		if a.loading == nil {
+			a.startLoad()
+		}
+		loading := a.loading
+		a.lock.Unlock()
+		<-loading
+		a.lock.Lock()
+		if a.buffer == nil && a.loadErr != nil && a.next >= a.end {
+			err := a.loadErr
+			a.loadErr = nil
+			a.lock.Unlock()
+			return 0, err
+		}
+	}
+	id := a.next
+	a.next++
+	if a.buffer == nil && a.loading == nil && float64(a.size-(a.end-a.next)) >= float64(a.size)*a.preload {
+		a.startLoad()
+	}
+	a.lock.Unlock()
+	return uint64(id), nil
+}
+
+//startLoad 在后台预取下一个号段，调用时必须持有锁
+func (a *SegmentAllocator) startLoad() {
+	done := make(chan struct{})
+	a.loading = done
+	go func() {
+		seg, err := a.load()
+		a.lock.Lock()
+		if err != nil {
+			log.Println("segment preload", a.key, err)
+			a.loadErr = err
+		} else {
+			a.buffer = seg
+			a.loadErr = nil
+		}
+		a.loading = nil
+		a.lock.Unlock()
+		close(done)
+	}()
+}
+
+//State 获取当前内部状态
+func (a *SegmentAllocator) State() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	buffered := a.buffer != nil
+	return fmt.Sprintf("key=%s,next=%d,end=%d,size=%d,buffered=%t", a.key, a.next, a.end, a.size, buffered)
+}
+
+//FileSegmentStore 基于本地文件的号段存储，每个key一个文件，保存已经分配出去的最大号码，
+//只适合单进程使用
+type FileSegmentStore struct {
+	dir  string
+	lock sync.Mutex
+}
+
+//NewFileSegmentStore ....
+func NewFileSegmentStore(dir string) (*FileSegmentStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileSegmentStore{dir: dir}, nil
+}
+
+//NextSegment ....
+func (s *FileSegmentStore) NextSegment(key string, size int64) (int64, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) {
+		return 0, errors.New("不合法的号段key")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	path := filepath.Join(s.dir, key+".seg")
+	var max int64
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		max, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return 0, err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(max+size, 10)), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+	return max + 1, nil
+}
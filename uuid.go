@@ -0,0 +1,151 @@
+package misc
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+//UUIDv4 随机UUID，带连字符的标准形式
+func UUIDv4() string {
+	return uuid.NewRandom().String()
+}
+
+var v7 struct {
+	lock    sync.Mutex
+	ms      int64
+	counter uint16
+}
+
+//UUIDv7 按时间排序的UUID（RFC 9562），前48位为毫秒时间戳，rand_a的12位作为同一毫秒内的计数器，
+//同一进程内生成的UUIDv7严格递增，计数器用完或时钟回拨时借用下一毫秒
+func UUIDv7() string {
+	var b [16]byte
+	randomBytes(b[6:])
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	v7.lock.Lock()
+	if ms <= v7.ms {
+		ms = v7.ms
+		v7.counter++
+		if v7.counter > 0xfff {
+			ms++
+			v7.counter = binary.BigEndian.Uint16(b[6:]) & 0x7ff
+		}
+	} else {
+		//最高位置0，给同一毫秒内的递增留出空间
+		v7.counter = binary.BigEndian.Uint16(b[6:]) & 0x7ff
+	}
+	v7.ms = ms
+	counter := v7.counter
+	v7.lock.Unlock()
+	putUint48(b[:6], uint64(ms))
+	binary.BigEndian.PutUint16(b[6:], 0x7000|counter)
+	b[8] = b[8]&0x3f | 0x80
+	return uuid.UUID(b[:]).String()
+}
+
+//ParseUUIDv7 返回UUIDv7中的时间，支持带或不带连字符的形式
+func ParseUUIDv7(s string) (time.Time, error) {
+	raw := strings.Replace(s, "-", "", -1)
+	if len(raw) != 32 {
+		return time.Time{}, &IDCodecError{"uuidv7", s, ErrIDLength}
+	}
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return time.Time{}, &IDCodecError{"uuidv7", s, ErrIDSyntax}
+	}
+	if b[6]>>4 != 7 || b[8]&0xc0 != 0x80 {
+		return time.Time{}, &IDCodecError{"uuidv7", s, ErrIDSyntax}
+	}
+	return msTime(uint48(b[:6])), nil
+}
+
+var ulid struct {
+	lock sync.Mutex
+	ms   int64
+	hi   uint16
+	lo   uint64
+}
+
+//ULID 26位Crockford base32编码，前48位为毫秒时间戳，后80位随机，
+//同一毫秒内在上一个随机数上加1保证严格递增，溢出时借用下一毫秒
+func ULID() string {
+	var r [10]byte
+	randomBytes(r[:])
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	ulid.lock.Lock()
+	if ms <= ulid.ms {
+		ms = ulid.ms
+		ulid.lo++
+		if ulid.lo == 0 {
+			ulid.hi++
+			if ulid.hi == 0 {
+				ms++
+				ulid.hi, ulid.lo = binary.BigEndian.Uint16(r[:]), binary.BigEndian.Uint64(r[2:])
+			}
+		}
+	} else {
+		ulid.hi, ulid.lo = binary.BigEndian.Uint16(r[:]), binary.BigEndian.Uint64(r[2:])
+	}
+	ulid.ms = ms
+	hi := uint64(ms)<<16 | uint64(ulid.hi)
+	lo := ulid.lo
+	ulid.lock.Unlock()
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockford32Alphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+//ParseULID 返回ULID中的时间，不区分大小写
+func ParseULID(s string) (time.Time, error) {
+	if len(s) != 26 {
+		return time.Time{}, &IDCodecError{"ulid", s, ErrIDLength}
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := base32Index[s[i]]
+		if v < 0 {
+			return time.Time{}, &IDCodecError{"ulid", s, ErrIDSyntax}
+		}
+		if i == 0 && v > 7 {
+			return time.Time{}, &IDCodecError{"ulid", s, ErrIDRange}
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	return msTime(hi >> 16), nil
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
+
+func putUint48(b []byte, v uint64) {
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+func uint48(b []byte) uint64 {
+	var v uint64
+	for _, c := range b[:6] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func msTime(ms uint64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}